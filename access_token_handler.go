@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/aarondever/chirpy/internal/auth"
+	"github.com/aarondever/chirpy/internal/database"
+	"github.com/aarondever/chirpy/internal/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	scopeChirpsRead    = "chirps:read"
+	scopeChirpsWrite   = "chirps:write"
+	scopeFollowsWrite  = "follows:write"
+	scopeWebhooksRead  = "webhooks:read"
+	scopeWebhooksWrite = "webhooks:write"
+)
+
+var knownScopes = []string{scopeChirpsRead, scopeChirpsWrite, scopeFollowsWrite, scopeWebhooksRead, scopeWebhooksWrite}
+
+const (
+	maxAccessTokenNameLength = 50
+	maxAccessTokenDays       = 365
+)
+
+var errInvalidAccessToken = errors.New("invalid personal access token")
+
+// scopeError is returned for a valid personal access token that wasn't
+// granted the scope an endpoint needs.
+type scopeError struct {
+	scope string
+}
+
+func (e *scopeError) Error() string {
+	if e.scope == "" {
+		return "Personal access tokens can't be used here"
+	}
+	return fmt.Sprintf("Token is missing the %s scope", e.scope)
+}
+
+type accessTokenResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Token      string     `json:"token,omitempty"`
+}
+
+func toAccessTokenResponse(token database.PersonalAccessToken) accessTokenResponse {
+	response := accessTokenResponse{
+		ID:        token.ID,
+		Name:      token.Name,
+		Scopes:    token.Scopes,
+		CreatedAt: token.CreatedAt,
+	}
+	if token.ExpiresAt.Valid {
+		response.ExpiresAt = &token.ExpiresAt.Time
+	}
+	if token.LastUsedAt.Valid {
+		response.LastUsedAt = &token.LastUsedAt.Time
+	}
+	return response
+}
+
+// handleCreateAccessToken returns the token itself only in this response;
+// afterwards just its hash is kept.
+func (cfg *apiConfig) handleCreateAccessToken(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.getUserFromToken(r, "")
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	var body struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays *int32   `json:"expires_in_days"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if body.Name == "" || len([]rune(body.Name)) > maxAccessTokenNameLength {
+		utils.RespondWithError(w, r, fmt.Sprintf("Name must be 1-%d characters", maxAccessTokenNameLength), http.StatusBadRequest)
+		return
+	}
+
+	if len(body.Scopes) == 0 {
+		utils.RespondWithError(w, r, "At least one scope is required", http.StatusBadRequest)
+		return
+	}
+	for _, scope := range body.Scopes {
+		if !slices.Contains(knownScopes, scope) {
+			utils.RespondWithError(w, r, fmt.Sprintf("Unknown scope %q", scope), http.StatusBadRequest)
+			return
+		}
+	}
+
+	expiresInDays := sql.NullInt32{}
+	if body.ExpiresInDays != nil {
+		if *body.ExpiresInDays < 1 || *body.ExpiresInDays > maxAccessTokenDays {
+			utils.RespondWithError(w, r, fmt.Sprintf("expires_in_days must be 1-%d", maxAccessTokenDays), http.StatusBadRequest)
+			return
+		}
+		expiresInDays = sql.NullInt32{Int32: *body.ExpiresInDays, Valid: true}
+	}
+
+	token := auth.MakePersonalAccessToken()
+	created, err := cfg.dbQueries.CreatePersonalAccessToken(r.Context(), database.CreatePersonalAccessTokenParams{
+		UserID:        userID,
+		Name:          body.Name,
+		TokenHash:     auth.HashToken(token),
+		Scopes:        slices.Compact(slices.Sorted(slices.Values(body.Scopes))),
+		ExpiresInDays: expiresInDays,
+	})
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := toAccessTokenResponse(created)
+	response.Token = token
+
+	utils.RespondWithJSON(w, r, response, http.StatusCreated)
+}
+
+func (cfg *apiConfig) handleGetAccessTokens(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.getUserFromToken(r, "")
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	tokens, err := cfg.dbQueries.GetPersonalAccessTokens(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]accessTokenResponse, 0, len(tokens))
+	for _, token := range tokens {
+		response = append(response, toAccessTokenResponse(token))
+	}
+
+	utils.RespondWithJSON(w, r, response, http.StatusOK)
+}
+
+func (cfg *apiConfig) handleRevokeAccessToken(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.getUserFromToken(r, "")
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	tokenID, err := uuid.Parse(r.PathValue("tokenID"))
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	revoked, err := cfg.dbQueries.RevokePersonalAccessToken(r.Context(), database.RevokePersonalAccessTokenParams{
+		ID:     tokenID,
+		UserID: userID,
+	})
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if revoked == 0 {
+		utils.RespondWithError(w, r, "Token not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) getUserFromAccessToken(ctx context.Context, token, scope string) (uuid.UUID, error) {
+	stored, err := cfg.dbQueries.GetPersonalAccessToken(ctx, auth.HashToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return uuid.UUID{}, errInvalidAccessToken
+		}
+		return uuid.UUID{}, err
+	}
+
+	if scope == "" || !slices.Contains(stored.Scopes, scope) {
+		return uuid.UUID{}, &scopeError{scope: scope}
+	}
+
+	if err := cfg.dbQueries.TouchPersonalAccessToken(ctx, stored.ID); err != nil {
+		return uuid.UUID{}, err
+	}
+
+	return stored.UserID, nil
+}
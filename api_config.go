@@ -1,22 +1,35 @@
 package main
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"sync/atomic"
 
 	"github.com/aarondever/chirpy/internal/auth"
 	"github.com/aarondever/chirpy/internal/database"
+	"github.com/aarondever/chirpy/internal/mailer"
 	"github.com/aarondever/chirpy/internal/utils"
+	"github.com/aarondever/chirpy/internal/webhooks"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type apiConfig struct {
-	fileserverHits atomic.Int32
-	platform       string
-	dbQueries      *database.Queries
-	jwtSecret      string
-	polkaKey       string
+	fileserverHits            atomic.Int32
+	platform                  string
+	db                        *sql.DB
+	dbQueries                 *database.Queries
+	jwtKeys                   *auth.KeySet
+	polkaKeys                 []string
+	mailer                    mailer.Mailer
+	baseURL                   string
+	emailVerificationRequired bool
+	freePlan                  entitlements
+	redPlan                   entitlements
+	webhookSender             *webhooks.Sender
 }
 
 func (cfg *apiConfig) middlewareMetricsInt(next http.Handler) http.Handler {
@@ -55,16 +68,111 @@ func (cfg *apiConfig) resetMetrics(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (cfg *apiConfig) getUserFromToken(r *http.Request) (uuid.UUID, error) {
+func (cfg *apiConfig) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	utils.RespondWithJSON(w, r, cfg.jwtKeys.JWKS(), http.StatusOK)
+}
+
+// getUserFromToken accepts a JWT, which can do anything the user can, or a
+// personal access token carrying scope. An empty scope means the endpoint
+// manages the account itself and only takes JWTs.
+func (cfg *apiConfig) getUserFromToken(r *http.Request, scope string) (uuid.UUID, error) {
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
 		return uuid.UUID{}, err
 	}
 
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if auth.IsPersonalAccessToken(token) {
+		return cfg.getUserFromAccessToken(r.Context(), token, scope)
+	}
+
+	userID, err := cfg.jwtKeys.ValidateJWT(token)
 	if err != nil {
 		return uuid.UUID{}, err
 	}
 
 	return userID, nil
 }
+
+// getViewerFromToken is getUserFromToken for endpoints that also serve
+// anonymous readers: a request without an Authorization header, or with a
+// personal access token lacking chirps:read, has no viewer.
+func (cfg *apiConfig) getViewerFromToken(r *http.Request) (uuid.NullUUID, error) {
+	if r.Header.Get("Authorization") == "" {
+		return uuid.NullUUID{}, nil
+	}
+
+	userID, err := cfg.getUserFromToken(r, scopeChirpsRead)
+	if err != nil {
+		// the endpoint is public, so a token that can't read is no worse
+		// than no token at all
+		var scopeErr *scopeError
+		if errors.As(err, &scopeErr) {
+			return uuid.NullUUID{}, nil
+		}
+		return uuid.NullUUID{}, err
+	}
+
+	return uuid.NullUUID{UUID: userID, Valid: true}, nil
+}
+
+// respondWithTokenError tells clients why their access token was refused, so
+// they know whether refreshing it will help.
+func respondWithTokenError(w http.ResponseWriter, r *http.Request, err error) {
+	var scopeErr *scopeError
+	if errors.As(err, &scopeErr) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope"`)
+		utils.RespondWithError(w, r, scopeErr.Error(), http.StatusForbidden)
+		return
+	}
+
+	msg := err.Error()
+
+	switch {
+	case errors.Is(err, errInvalidAccessToken):
+		msg = "Invalid personal access token"
+	case errors.Is(err, auth.ErrTokenExpired):
+		msg = "Token expired"
+	case errors.Is(err, auth.ErrTokenMalformed):
+		msg = "Malformed token"
+	case errors.Is(err, auth.ErrTokenSignature):
+		msg = "Invalid token signature"
+	case errors.Is(err, auth.ErrTokenClaims):
+		msg = "Invalid token claims"
+	}
+
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	utils.RespondWithError(w, r, msg, http.StatusUnauthorized)
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
+
+func toNullString(s *string) sql.NullString {
+	if s == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *s, Valid: true}
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// clientIP is the address the request came from, without the port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
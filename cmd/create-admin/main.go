@@ -0,0 +1,86 @@
+// Command create-admin makes a user an admin, creating the user first when the
+// email isn't signed up yet. It is how the first admin gets in:
+//
+//	go run ./cmd/create-admin -email admin@example.com -password hunter2
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/aarondever/chirpy/internal/auth"
+	"github.com/aarondever/chirpy/internal/database"
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	email := flag.String("email", "", "email of the user to make an admin")
+	password := flag.String("password", "", "password, only used when the user has to be created")
+	flag.Parse()
+
+	if *email == "" {
+		log.Fatal("-email is required")
+	}
+
+	godotenv.Load()
+	db, err := sql.Open("postgres", os.Getenv("DB_URL"))
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	queries := database.New(db)
+
+	user, err := queries.GetUserByEmail(ctx, *email)
+	if errors.Is(err, sql.ErrNoRows) {
+		if *password == "" {
+			log.Fatal("no user with that email, -password is required to create one")
+		}
+
+		user, err = createUser(ctx, queries, *email, *password)
+	}
+	if err != nil {
+		log.Fatalf("Failed to get user: %v", err)
+	}
+
+	if _, err := queries.SetUserRole(ctx, database.SetUserRoleParams{
+		ID:   user.ID,
+		Role: "admin",
+	}); err != nil {
+		log.Fatalf("Failed to set role: %v", err)
+	}
+
+	log.Printf("%s (%s) is now an admin", user.Email, user.ID)
+}
+
+// createUser signs the admin up with the email already verified, since
+// whoever runs this has access to the database anyway.
+func createUser(ctx context.Context, queries *database.Queries, email, password string) (database.User, error) {
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	user, err := queries.CreateUser(ctx, database.CreateUserParams{
+		Email:          email,
+		HashedPassword: hash,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+
+	if _, err := queries.VerifyUserEmail(ctx, database.VerifyUserEmailParams{
+		ID:    user.ID,
+		Email: user.Email,
+	}); err != nil {
+		return database.User{}, err
+	}
+
+	return user, nil
+}
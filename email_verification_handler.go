@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+
+	"github.com/aarondever/chirpy/internal/auth"
+	"github.com/aarondever/chirpy/internal/database"
+	"github.com/aarondever/chirpy/internal/mailer"
+	"github.com/aarondever/chirpy/internal/utils"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handleVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		utils.RespondWithError(w, r, "Missing token", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	verification, err := qtx.UseEmailVerificationToken(r.Context(), auth.HashToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, r, "Invalid or expired verification token", http.StatusBadRequest)
+			return
+		}
+
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// the token only vouches for the address it was sent to
+	verified, err := qtx.VerifyUserEmail(r.Context(), database.VerifyUserEmailParams{
+		ID:    verification.UserID,
+		Email: verification.Email,
+	})
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if verified == 0 {
+		utils.RespondWithError(w, r, "Email address has changed since this token was sent", http.StatusBadRequest)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleResendVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.getUserFromToken(r, "")
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if user.EmailVerifiedAt.Valid {
+		utils.RespondWithError(w, r, "Email already verified", http.StatusConflict)
+		return
+	}
+
+	if err := cfg.sendVerificationEmail(r.Context(), user); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// sendVerificationEmail mails the user a link that verifies their current
+// email address.
+func (cfg *apiConfig) sendVerificationEmail(ctx context.Context, user database.User) error {
+	token := auth.MakeRandomToken()
+	if err := cfg.dbQueries.CreateEmailVerificationToken(ctx, database.CreateEmailVerificationTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    user.ID,
+		Email:     user.Email,
+	}); err != nil {
+		return err
+	}
+
+	return cfg.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your Chirpy email address",
+		Body: fmt.Sprintf("Welcome to Chirpy! Confirm this is your email address by opening the link below "+
+			"within the next 24 hours:\n\n%s/api/verify-email?token=%s", cfg.baseURL, token),
+	})
+}
+
+// requireVerifiedEmail enforces the verification policy, writing a 403 and
+// reporting false when it is on and the user hasn't verified their email.
+func (cfg *apiConfig) requireVerifiedEmail(w http.ResponseWriter, r *http.Request, userID uuid.UUID) bool {
+	if !cfg.emailVerificationRequired {
+		return true
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+
+	if !user.EmailVerifiedAt.Valid {
+		utils.RespondWithError(w, r, "Verify your email address first", http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+func validateEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return errors.New("Invalid email address")
+	}
+	return nil
+}
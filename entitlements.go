@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/aarondever/chirpy/internal/utils"
+	"github.com/google/uuid"
+)
+
+// entitlements are what a plan lets its users do. Every Chirpy Red check goes
+// through them rather than looking at the subscription directly.
+type entitlements struct {
+	MaxChirpLength int
+	ChirpsPerHour  int
+	CanEditChirps  bool
+	// MaxScheduledChirps is how many chirps can wait to be published at once;
+	// zero means the plan can't schedule chirps.
+	MaxScheduledChirps int
+}
+
+var (
+	defaultFreePlan = entitlements{
+		MaxChirpLength: 140,
+		ChirpsPerHour:  30,
+	}
+	defaultRedPlan = entitlements{
+		MaxChirpLength:     560,
+		ChirpsPerHour:      300,
+		CanEditChirps:      true,
+		MaxScheduledChirps: 100,
+	}
+)
+
+// loadEntitlements reads a plan's limits from the environment, each variable
+// named with the given prefix, e.g. RED_CHIRP_MAX_LENGTH. Unset variables keep
+// the defaults.
+func loadEntitlements(prefix string, defaults entitlements) (entitlements, error) {
+	plan := defaults
+
+	ints := []struct {
+		key string
+		dst *int
+		min int
+	}{
+		{"CHIRP_MAX_LENGTH", &plan.MaxChirpLength, 1},
+		{"CHIRPS_PER_HOUR", &plan.ChirpsPerHour, 1},
+		{"SCHEDULED_CHIRPS_MAX", &plan.MaxScheduledChirps, 0},
+	}
+
+	for _, v := range ints {
+		s := os.Getenv(prefix + v.key)
+		if s == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(s)
+		if err != nil || n < v.min {
+			return entitlements{}, fmt.Errorf("%s%s must be an integer of at least %d", prefix, v.key, v.min)
+		}
+		*v.dst = n
+	}
+
+	if s := os.Getenv(prefix + "CHIRP_EDITS"); s != "" {
+		canEdit, err := strconv.ParseBool(s)
+		if err != nil {
+			return entitlements{}, fmt.Errorf("%sCHIRP_EDITS: %w", prefix, err)
+		}
+		plan.CanEditChirps = canEdit
+	}
+
+	return plan, nil
+}
+
+func (cfg *apiConfig) entitlementsFor(ctx context.Context, userID uuid.UUID) (entitlements, error) {
+	isChirpyRed, err := cfg.isChirpyRed(ctx, userID)
+	if err != nil {
+		return entitlements{}, err
+	}
+
+	if isChirpyRed {
+		return cfg.redPlan, nil
+	}
+	return cfg.freePlan, nil
+}
+
+// getEntitlements writes a 500 and reports false when the user's plan can't
+// be looked up.
+func (cfg *apiConfig) getEntitlements(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (entitlements, bool) {
+	plan, err := cfg.entitlementsFor(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return entitlements{}, false
+	}
+
+	return plan, true
+}
+
+// checkChirpRate writes a 429 and reports false once the user has posted as
+// many chirps in the last hour as their plan allows.
+func (cfg *apiConfig) checkChirpRate(w http.ResponseWriter, r *http.Request, userID uuid.UUID, plan entitlements) bool {
+	rate, err := cfg.dbQueries.GetChirpRate(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+
+	if int(rate.ChirpCount) >= plan.ChirpsPerHour {
+		w.Header().Set("Retry-After", strconv.Itoa(int(max(rate.RetryAfter, 1))))
+		utils.RespondWithError(w, r, "Too many chirps, try again later", http.StatusTooManyRequests)
+		return false
+	}
+
+	return true
+}
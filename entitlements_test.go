@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestLoadEntitlements(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    entitlements
+		wantErr bool
+	}{
+		{name: "defaults", want: defaultRedPlan},
+		{
+			name: "overrides",
+			env: map[string]string{
+				"TEST_CHIRP_MAX_LENGTH":     "280",
+				"TEST_CHIRPS_PER_HOUR":      "10",
+				"TEST_SCHEDULED_CHIRPS_MAX": "0",
+				"TEST_CHIRP_EDITS":          "false",
+			},
+			want: entitlements{MaxChirpLength: 280, ChirpsPerHour: 10},
+		},
+		{
+			name: "partial override",
+			env:  map[string]string{"TEST_CHIRP_MAX_LENGTH": "1000"},
+			want: entitlements{MaxChirpLength: 1000, ChirpsPerHour: 300, CanEditChirps: true, MaxScheduledChirps: 100},
+		},
+		{name: "not a number", env: map[string]string{"TEST_CHIRPS_PER_HOUR": "lots"}, wantErr: true},
+		{name: "below minimum", env: map[string]string{"TEST_CHIRP_MAX_LENGTH": "0"}, wantErr: true},
+		{name: "negative scheduled", env: map[string]string{"TEST_SCHEDULED_CHIRPS_MAX": "-1"}, wantErr: true},
+		{name: "bad bool", env: map[string]string{"TEST_CHIRP_EDITS": "maybe"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+
+			plan, err := loadEntitlements("TEST_", defaultRedPlan)
+			assert.Equal(t, err != nil, test.wantErr)
+			if test.wantErr {
+				return
+			}
+			assert.Equal(t, plan, test.want)
+		})
+	}
+}
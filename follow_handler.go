@@ -0,0 +1,236 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aarondever/chirpy/internal/database"
+	"github.com/aarondever/chirpy/internal/utils"
+	"github.com/google/uuid"
+)
+
+type followResponse struct {
+	UserID     uuid.UUID `json:"user_id"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+type followPageResponse struct {
+	Users      []followResponse `json:"users"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+func (cfg *apiConfig) handleFollowUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.getUserFromToken(r, scopeFollowsWrite)
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	followee, ok := cfg.getPathUser(w, r)
+	if !ok {
+		return
+	}
+
+	if followee.ID == userID {
+		utils.RespondWithError(w, r, "You cannot follow yourself", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	followed, err := qtx.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: userID,
+		FolloweeID: followee.ID,
+	})
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// following someone again isn't news
+	if followed > 0 {
+		if err := enqueueWebhookEvent(r.Context(), qtx, followee.ID, eventUserFollowed, followEventData{
+			UserID:     followee.ID,
+			FollowerID: userID,
+		}); err != nil {
+			utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleUnfollowUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.getUserFromToken(r, scopeFollowsWrite)
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	followee, ok := cfg.getPathUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := cfg.dbQueries.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: userID,
+		FolloweeID: followee.ID,
+	}); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleGetFollowers(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.getPathUser(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := parsePageParams(r.URL.Query())
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := cfg.dbQueries.GetFollowers(r.Context(), database.GetFollowersParams{
+		UserID:          user.ID,
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		PageSize:        page.fetchSize(),
+	})
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	follows := make([]followResponse, 0, len(rows))
+	for _, row := range rows {
+		follows = append(follows, followResponse{
+			UserID:     row.FollowerID,
+			FollowedAt: row.CreatedAt,
+		})
+	}
+
+	respondWithFollowPage(w, r, page, follows)
+}
+
+func (cfg *apiConfig) handleGetFollowing(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.getPathUser(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := parsePageParams(r.URL.Query())
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := cfg.dbQueries.GetFollowing(r.Context(), database.GetFollowingParams{
+		UserID:          user.ID,
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		PageSize:        page.fetchSize(),
+	})
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	follows := make([]followResponse, 0, len(rows))
+	for _, row := range rows {
+		follows = append(follows, followResponse{
+			UserID:     row.FolloweeID,
+			FollowedAt: row.CreatedAt,
+		})
+	}
+
+	respondWithFollowPage(w, r, page, follows)
+}
+
+func (cfg *apiConfig) handleGetTimeline(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.getUserFromToken(r, scopeChirpsRead)
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	page, err := parsePageParams(r.URL.Query())
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	chirps, err := cfg.dbQueries.GetTimeline(r.Context(), database.GetTimelineParams{
+		UserID:          userID,
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		PageSize:        page.fetchSize(),
+	})
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	chirps, nextCursor := trimPage(page, chirps, chirpCursorOf)
+
+	cfg.respondWithChirpPage(w, r, uuid.NullUUID{UUID: userID, Valid: true}, chirps, nextCursor)
+}
+
+// getPathUser loads the user named by the {userID} path value, writing the
+// error response itself when it can't.
+func (cfg *apiConfig) getPathUser(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	v := r.PathValue("userID")
+	userID, err := uuid.Parse(v)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return database.User{}, false
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, r, "User not found", http.StatusNotFound)
+			return database.User{}, false
+		}
+
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return database.User{}, false
+	}
+
+	return user, true
+}
+
+func respondWithFollowPage(w http.ResponseWriter, r *http.Request, page pageParams, follows []followResponse) {
+	follows, nextCursor := trimPage(page, follows, func(f followResponse) pageCursor {
+		return pageCursor{
+			CreatedAt: f.FollowedAt,
+			ID:        f.UserID,
+		}
+	})
+
+	response := followPageResponse{
+		Users:      follows,
+		NextCursor: nextCursor,
+	}
+
+	utils.RespondWithJSON(w, r, response, http.StatusOK)
+}
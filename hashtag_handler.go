@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/aarondever/chirpy/internal/database"
+	"github.com/aarondever/chirpy/internal/utils"
+)
+
+const (
+	defaultTrendingWindow = 24 * time.Hour
+	maxTrendingWindow     = 7 * 24 * time.Hour
+	trendingTagLimit      = 10
+)
+
+var hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&#])#([\p{L}\p{N}_]{1,50})`)
+
+type trendingHashtagResponse struct {
+	Tag        string `json:"tag"`
+	ChirpCount int64  `json:"chirp_count"`
+}
+
+func (cfg *apiConfig) handleGetHashtagChirps(w http.ResponseWriter, r *http.Request) {
+	viewer, err := cfg.getViewerFromToken(r)
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	page, err := parsePageParams(r.URL.Query())
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	chirps, err := cfg.dbQueries.GetHashtagChirps(r.Context(), database.GetHashtagChirpsParams{
+		Tag:             normalizeHashtag(r.PathValue("tag")),
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		PageSize:        page.fetchSize(),
+	})
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	chirps, nextCursor := trimPage(page, chirps, chirpCursorOf)
+
+	cfg.respondWithChirpPage(w, r, viewer, chirps, nextCursor)
+}
+
+func (cfg *apiConfig) handleGetTrendingHashtags(w http.ResponseWriter, r *http.Request) {
+	window := defaultTrendingWindow
+	if v := r.URL.Query().Get("hours"); v != "" {
+		hours, err := strconv.Atoi(v)
+		if err != nil || hours < 1 {
+			utils.RespondWithError(w, r, "hours must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		window = min(time.Duration(hours)*time.Hour, maxTrendingWindow)
+	}
+
+	// the window is measured back from the database's NOW(), the same clock
+	// that stamped created_at
+	rows, err := cfg.dbQueries.GetTrendingHashtags(r.Context(), database.GetTrendingHashtagsParams{
+		Hours:    int32(window / time.Hour),
+		TagLimit: trendingTagLimit,
+	})
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var response = make([]trendingHashtagResponse, 0, len(rows))
+	for _, row := range rows {
+		response = append(response, trendingHashtagResponse{
+			Tag:        row.Tag,
+			ChirpCount: row.ChirpCount,
+		})
+	}
+
+	utils.RespondWithJSON(w, r, response, http.StatusOK)
+}
+
+// tagChirp replaces the hashtags recorded for chirp with the ones in its body.
+func tagChirp(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	if err := q.UntagChirp(ctx, chirp.ID); err != nil {
+		return err
+	}
+
+	tags := extractHashtags(chirp.Body)
+	if len(tags) == 0 {
+		return nil
+	}
+
+	if err := q.CreateHashtags(ctx, tags); err != nil {
+		return err
+	}
+
+	return q.TagChirp(ctx, database.TagChirpParams{
+		ChirpID: chirp.ID,
+		Tags:    tags,
+	})
+}
+
+func extractHashtags(body string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, match := range hashtagPattern.FindAllStringSubmatch(body, -1) {
+		tag := normalizeHashtag(match[1])
+		// all-digit tags like #1 are more likely numbering than topics
+		if seen[tag] || !strings.ContainsFunc(tag, unicode.IsLetter) {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+
+	return tags
+}
+
+func normalizeHashtag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(tag, "#"))
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestExtractHashtags(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{name: "none", body: "just a chirp", want: nil},
+		{name: "single", body: "learning #golang today", want: []string{"golang"}},
+		{name: "start of body", body: "#chirpy is live", want: []string{"chirpy"}},
+		{name: "lowercased", body: "#GoLang", want: []string{"golang"}},
+		{name: "deduplicated", body: "#go and #Go and #GO", want: []string{"go"}},
+		{name: "order kept", body: "#b then #a", want: []string{"b", "a"}},
+		{name: "punctuation ends tag", body: "so good #pizza!", want: []string{"pizza"}},
+		{name: "underscores and digits", body: "#web_3 #go2026", want: []string{"web_3", "go2026"}},
+		{name: "unicode letters", body: "#café", want: []string{"café"}},
+		{name: "all digits skipped", body: "issue #123", want: nil},
+		{name: "mid-word skipped", body: "email me at a#b", want: nil},
+		{name: "html entity skipped", body: "it&#39;s fine", want: nil},
+		{name: "double hash skipped", body: "##nope", want: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, extractHashtags(test.body), test.want)
+		})
+	}
+}
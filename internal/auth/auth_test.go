@@ -56,3 +56,30 @@ func TestGetBearerToken(t *testing.T) {
 		assert.Equal(t, token, test.token)
 	})
 }
+
+func TestHashToken(t *testing.T) {
+	test := struct {
+		name  string
+		token string
+		want  string
+	}{
+		name:  "test01",
+		token: "abc",
+		want:  "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+	}
+
+	t.Run(test.name, func(t *testing.T) {
+		assert.Equal(t, HashToken(test.token), test.want)
+	})
+}
+
+func TestIsPersonalAccessToken(t *testing.T) {
+	token := MakePersonalAccessToken()
+	assert.Equal(t, IsPersonalAccessToken(token), true)
+
+	jwt, err := MakeJWT(uuid.New(), "secret", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, IsPersonalAccessToken(jwt), false)
+}
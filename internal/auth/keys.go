@@ -0,0 +1,340 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"maps"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Key is one JWT key. Asymmetric keys loaded from a public key file can only
+// verify; HMAC keys verify with their own secret and never show up in the JWKS.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	// private is what signs: an HMAC secret, *rsa.PrivateKey or ed25519.PrivateKey
+	private any
+	// public is what verifies: the same HMAC secret, *rsa.PublicKey or ed25519.PublicKey
+	public any
+}
+
+// KeySet signs tokens with one key and verifies them with any of its keys,
+// chosen by the token's kid header. Keeping retired keys around lets tokens
+// signed before a rotation live out their expiry.
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+	opts    TokenOptions
+}
+
+// TokenOptions are the claims and algorithms a KeySet puts in the tokens it
+// makes and insists on in the tokens it validates.
+type TokenOptions struct {
+	Issuer string
+	// Audience is left out of tokens, and not checked, when empty.
+	Audience string
+	// Algorithms defaults to the algorithms of the keys in the set.
+	Algorithms []string
+	// Leeway allows for clock skew when checking exp, nbf and iat.
+	Leeway time.Duration
+}
+
+var (
+	ErrTokenExpired   = errors.New("token has expired")
+	ErrTokenMalformed = errors.New("token is malformed")
+	ErrTokenSignature = errors.New("token signature is invalid")
+	ErrTokenClaims    = errors.New("token claims are invalid")
+)
+
+var defaultTokenOptions = TokenOptions{Issuer: "chirpy"}
+
+// JWK is the public half of a key in RFC 7517 form.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewHMACKeySet is a key set holding a single HS256 secret. Its tokens carry
+// no kid.
+func NewHMACKeySet(secret string) *KeySet {
+	key := &Key{
+		Method:  jwt.SigningMethodHS256,
+		private: []byte(secret),
+		public:  []byte(secret),
+	}
+
+	return &KeySet{
+		signing: key,
+		keys:    map[string]*Key{"": key},
+		opts:    defaultTokenOptions,
+	}
+}
+
+// LoadKeySet reads every *.pem file in dir, using the file name without its
+// extension as the kid. Files may hold PKCS#8 (RSA or Ed25519) or PKCS#1 RSA
+// private keys, or PKIX public keys for retired keys that only verify.
+// signingKeyID picks the key that signs; it may be empty when dir holds a
+// single private key.
+func LoadKeySet(dir, signingKeyID string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := &KeySet{
+		keys: map[string]*Key{},
+		opts: defaultTokenOptions,
+	}
+	var privateKeys []*Key
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := parseKey(kid, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		keys.keys[kid] = key
+		if key.private != nil {
+			privateKeys = append(privateKeys, key)
+		}
+	}
+
+	switch {
+	case signingKeyID != "":
+		key, ok := keys.keys[signingKeyID]
+		if !ok || key.private == nil {
+			return nil, fmt.Errorf("no private key %q in %s", signingKeyID, dir)
+		}
+		keys.signing = key
+	case len(privateKeys) == 1:
+		keys.signing = privateKeys[0]
+	default:
+		return nil, fmt.Errorf("%s holds %d private keys, set the signing key id", dir, len(privateKeys))
+	}
+
+	return keys, nil
+}
+
+// AddHMACSecret lets the set keep verifying HS256 tokens without a kid, for
+// tokens signed before the switch to asymmetric keys.
+func (ks *KeySet) AddHMACSecret(secret string) {
+	if _, ok := ks.keys[""]; ok {
+		return
+	}
+
+	ks.keys[""] = NewHMACKeySet(secret).signing
+}
+
+// SetOptions replaces the token options. It refuses unknown algorithm names and
+// algorithm lists that would reject the set's own tokens.
+func (ks *KeySet) SetOptions(opts TokenOptions) error {
+	for _, alg := range opts.Algorithms {
+		if jwt.GetSigningMethod(alg) == nil {
+			return fmt.Errorf("unknown signing algorithm %q", alg)
+		}
+	}
+
+	if len(opts.Algorithms) > 0 && !slices.Contains(opts.Algorithms, ks.signing.Method.Alg()) {
+		return fmt.Errorf("signing algorithm %s is not in the allowed algorithms", ks.signing.Method.Alg())
+	}
+
+	ks.opts = opts
+	return nil
+}
+
+func (ks *KeySet) MakeJWT(userID uuid.UUID, expiresIn time.Duration) (string, error) {
+	claims := jwt.RegisteredClaims{
+		Issuer:    ks.opts.Issuer,
+		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+		Subject:   userID.String(),
+	}
+	if ks.opts.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{ks.opts.Audience}
+	}
+
+	token := jwt.NewWithClaims(ks.signing.Method, claims)
+
+	if ks.signing.ID != "" {
+		token.Header["kid"] = ks.signing.ID
+	}
+
+	return token.SignedString(ks.signing.private)
+}
+
+// ValidateJWT returns the token's user. Its errors wrap one of ErrTokenExpired,
+// ErrTokenMalformed, ErrTokenSignature or ErrTokenClaims.
+func (ks *KeySet) ValidateJWT(tokenString string) (uuid.UUID, error) {
+	parserOptions := []jwt.ParserOption{
+		jwt.WithValidMethods(ks.algorithms()),
+		jwt.WithLeeway(ks.opts.Leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithIssuer(ks.opts.Issuer),
+	}
+	if ks.opts.Audience != "" {
+		parserOptions = append(parserOptions, jwt.WithAudience(ks.opts.Audience))
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, ks.keyFunc, parserOptions...)
+	if err != nil {
+		return uuid.UUID{}, classifyJWTError(err)
+	}
+
+	id, err := token.Claims.GetSubject()
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("%w: %w", ErrTokenMalformed, err)
+	}
+
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("%w: subject: %w", ErrTokenClaims, err)
+	}
+
+	return userID, nil
+}
+
+func (ks *KeySet) algorithms() []string {
+	if len(ks.opts.Algorithms) > 0 {
+		return ks.opts.Algorithms
+	}
+
+	var algs []string
+	for _, key := range ks.keys {
+		if !slices.Contains(algs, key.Method.Alg()) {
+			algs = append(algs, key.Method.Alg())
+		}
+	}
+	return algs
+}
+
+func classifyJWTError(err error) error {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return fmt.Errorf("%w: %w", ErrTokenExpired, err)
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return fmt.Errorf("%w: %w", ErrTokenMalformed, err)
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return fmt.Errorf("%w: %w", ErrTokenSignature, err)
+	default:
+		return fmt.Errorf("%w: %w", ErrTokenClaims, err)
+	}
+}
+
+// keyFunc finds the key named by the token's kid and refuses tokens whose alg
+// doesn't match it, so a public key can never be used as an HMAC secret.
+func (ks *KeySet) keyFunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+	}
+
+	return key.public, nil
+}
+
+// JWKS lists the public keys other services need to verify our tokens.
+func (ks *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+
+	for _, kid := range slices.Sorted(maps.Keys(ks.keys)) {
+		key := ks.keys[kid]
+		jwk := JWK{
+			KeyID:     key.ID,
+			Use:       "sig",
+			Algorithm: key.Method.Alg(),
+		}
+
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks
+}
+
+func parseKey(kid string, data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed any
+	var err error
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{ID: kid}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.private, key.public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case ed25519.PrivateKey:
+		key.Method, key.private, key.public = jwt.SigningMethodEdDSA, k, k.Public()
+	case *rsa.PublicKey:
+		key.Method, key.public = jwt.SigningMethodRS256, k
+	case ed25519.PublicKey:
+		key.Method, key.public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	return key, nil
+}
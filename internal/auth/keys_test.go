@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/google/uuid"
+)
+
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) {
+	t.Helper()
+
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, name+".pem"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, "old", "PRIVATE KEY", der)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, "new", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+
+	userID := uuid.New()
+
+	oldKeys, err := LoadKeySet(dir, "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldToken, err := oldKeys.MakeJWT(userID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// retire the old key: only its public half stays around
+	der, err = x509.MarshalPKIXPublicKey(edKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, dir, "old", "PUBLIC KEY", der)
+
+	newKeys, err := LoadKeySet(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newToken, err := newKeys.MakeJWT(userID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hmacToken, err := MakeJWT(userID, "secret", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		tokenString string
+		wantUserID  uuid.UUID
+		wantErr     bool
+	}{
+		{name: "retired key", tokenString: oldToken, wantUserID: userID},
+		{name: "signing key", tokenString: newToken, wantUserID: userID},
+		{name: "no hmac secret", tokenString: hmacToken, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gotUserID, err := newKeys.ValidateJWT(test.tokenString)
+			assert.Equal(t, err != nil, test.wantErr)
+			assert.Equal(t, gotUserID, test.wantUserID)
+		})
+	}
+
+	t.Run("hmac secret", func(t *testing.T) {
+		newKeys.AddHMACSecret("secret")
+		gotUserID, err := newKeys.ValidateJWT(hmacToken)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, gotUserID, userID)
+	})
+
+	t.Run("jwks", func(t *testing.T) {
+		jwks := newKeys.JWKS()
+		assert.Equal(t, len(jwks.Keys), 2)
+		assert.Equal(t, jwks.Keys[0].KeyID, "new")
+		assert.Equal(t, jwks.Keys[0].Algorithm, "RS256")
+		assert.Equal(t, jwks.Keys[0].E, "AQAB")
+		assert.Equal(t, jwks.Keys[1].KeyID, "old")
+		assert.Equal(t, jwks.Keys[1].Algorithm, "EdDSA")
+		assert.Equal(t, jwks.Keys[1].Curve, "Ed25519")
+	})
+}
+
+func TestValidateJWTErrors(t *testing.T) {
+	userID := uuid.New()
+
+	keys := NewHMACKeySet("secret")
+	if err := keys.SetOptions(TokenOptions{Issuer: "chirpy", Audience: "chirpy-api", Leeway: time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(keys *KeySet, expiresIn time.Duration) string {
+		token, err := keys.MakeJWT(userID, expiresIn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	otherIssuer := NewHMACKeySet("secret")
+	otherIssuer.SetOptions(TokenOptions{Issuer: "someone-else", Audience: "chirpy-api"})
+
+	otherAudience := NewHMACKeySet("secret")
+	otherAudience.SetOptions(TokenOptions{Issuer: "chirpy", Audience: "elsewhere"})
+
+	tests := []struct {
+		name        string
+		tokenString string
+		wantErr     error
+	}{
+		{name: "valid", tokenString: sign(keys, time.Hour)},
+		{name: "within leeway", tokenString: sign(keys, -30*time.Second)},
+		{name: "expired", tokenString: sign(keys, -time.Hour), wantErr: ErrTokenExpired},
+		{name: "malformed", tokenString: "abc", wantErr: ErrTokenMalformed},
+		{name: "wrong secret", tokenString: sign(NewHMACKeySet("other"), time.Hour), wantErr: ErrTokenSignature},
+		{name: "wrong issuer", tokenString: sign(otherIssuer, time.Hour), wantErr: ErrTokenClaims},
+		{name: "wrong audience", tokenString: sign(otherAudience, time.Hour), wantErr: ErrTokenClaims},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := keys.ValidateJWT(test.tokenString)
+			if test.wantErr == nil {
+				assert.Equal(t, err, nil)
+				return
+			}
+			assert.Equal(t, errors.Is(err, test.wantErr), true)
+		})
+	}
+
+	t.Run("algorithm not allowed", func(t *testing.T) {
+		err := keys.SetOptions(TokenOptions{Issuer: "chirpy", Algorithms: []string{"RS256"}})
+		assert.NotEqual(t, err, nil)
+	})
+
+	t.Run("unknown algorithm", func(t *testing.T) {
+		err := keys.SetOptions(TokenOptions{Issuer: "chirpy", Algorithms: []string{"HS256", " EdDSA"}})
+		assert.NotEqual(t, err, nil)
+	})
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestValidateTOTP(t *testing.T) {
+	// the RFC 6238 SHA1 test key, "12345678901234567890"
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	tests := []struct {
+		name     string
+		code     string
+		time     time.Time
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "rfc 59", code: "287082", time: time.Unix(59, 0), wantStep: 1, wantOK: true},
+		{name: "rfc 1111111109", code: "081804", time: time.Unix(1111111109, 0), wantStep: 37037036, wantOK: true},
+		{name: "rfc 1234567890", code: "005924", time: time.Unix(1234567890, 0), wantStep: 41152263, wantOK: true},
+		{name: "previous period", code: "005924", time: time.Unix(1234567890+totpPeriod, 0), wantStep: 41152263, wantOK: true},
+		{name: "too old", code: "005924", time: time.Unix(1234567890+3*totpPeriod, 0)},
+		{name: "wrong code", code: "123456", time: time.Unix(1234567890, 0)},
+		{name: "wrong length", code: "5924", time: time.Unix(1234567890, 0)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			step, ok := ValidateTOTP(secret, test.code, test.time)
+			assert.Equal(t, ok, test.wantOK)
+			assert.Equal(t, step, test.wantStep)
+		})
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes := GenerateRecoveryCodes(10)
+	assert.Equal(t, len(codes), 10)
+
+	for _, code := range codes {
+		assert.Equal(t, len(code), 11)
+		assert.Equal(t, NormalizeRecoveryCode(" "+strings.ToUpper(code)), strings.ReplaceAll(code, "-", ""))
+	}
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrWebhookTimestamp = errors.New("webhook timestamp is missing or outside the tolerance window")
+	ErrWebhookSignature = errors.New("webhook signature is invalid")
+)
+
+// SignWebhook is the hex HMAC-SHA256 of "<timestamp>.<body>". Signing the
+// timestamp along with the body stops an old delivery being replayed with a
+// fresh timestamp.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature accepts a signature made with any of secrets, so the
+// old and new secret both work while one is being rotated out.
+func VerifyWebhookSignature(secrets []string, timestamp, signature string, body []byte, now time.Time, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrWebhookTimestamp
+	}
+
+	if age := now.Sub(time.Unix(ts, 0)); age > tolerance || age < -tolerance {
+		return ErrWebhookTimestamp
+	}
+
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return ErrWebhookSignature
+	}
+
+	valid := false
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+
+		want, _ := hex.DecodeString(SignWebhook(secret, ts, body))
+		if hmac.Equal(got, want) {
+			valid = true
+		}
+	}
+
+	if !valid {
+		return ErrWebhookSignature
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"event":"user.upgraded"}`)
+	now := time.Unix(1700000000, 0)
+	ts := now.Unix()
+
+	tests := []struct {
+		name      string
+		secrets   []string
+		timestamp string
+		signature string
+		body      []byte
+		wantErr   error
+	}{
+		{
+			name:      "current key",
+			secrets:   []string{"new", "old"},
+			timestamp: strconv.FormatInt(ts, 10),
+			signature: SignWebhook("new", ts, body),
+			body:      body,
+		},
+		{
+			name:      "previous key",
+			secrets:   []string{"new", "old"},
+			timestamp: strconv.FormatInt(ts, 10),
+			signature: SignWebhook("old", ts, body),
+			body:      body,
+		},
+		{
+			name:      "retired key",
+			secrets:   []string{"new"},
+			timestamp: strconv.FormatInt(ts, 10),
+			signature: SignWebhook("old", ts, body),
+			body:      body,
+			wantErr:   ErrWebhookSignature,
+		},
+		{
+			name:      "tampered body",
+			secrets:   []string{"new"},
+			timestamp: strconv.FormatInt(ts, 10),
+			signature: SignWebhook("new", ts, body),
+			body:      []byte(`{"event":"user.downgraded"}`),
+			wantErr:   ErrWebhookSignature,
+		},
+		{
+			name:      "replayed with new timestamp",
+			secrets:   []string{"new"},
+			timestamp: strconv.FormatInt(ts, 10),
+			signature: SignWebhook("new", ts-600, body),
+			body:      body,
+			wantErr:   ErrWebhookSignature,
+		},
+		{
+			name:      "too old",
+			secrets:   []string{"new"},
+			timestamp: strconv.FormatInt(ts-600, 10),
+			signature: SignWebhook("new", ts-600, body),
+			body:      body,
+			wantErr:   ErrWebhookTimestamp,
+		},
+		{
+			name:      "missing timestamp",
+			secrets:   []string{"new"},
+			signature: SignWebhook("new", ts, body),
+			body:      body,
+			wantErr:   ErrWebhookTimestamp,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := VerifyWebhookSignature(test.secrets, test.timestamp, test.signature, test.body, now, 5*time.Minute)
+			assert.Equal(t, err, test.wantErr)
+		})
+	}
+}
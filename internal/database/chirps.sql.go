@@ -7,29 +7,46 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, parent_id, root_id, kind, original_id, edited_at, search_vector)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, parent_id, root_id, like_count, kind, original_id, edited_at, search_vector
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body       string
+	UserID     uuid.UUID
+	ParentID   uuid.NullUUID
+	RootID     uuid.NullUUID
+	Kind       string
+	OriginalID uuid.NullUUID
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.ParentID,
+		arg.RootID,
+		arg.Kind,
+		arg.OriginalID,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,6 +54,13 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.ParentID,
+		&i.RootID,
+		&i.LikeCount,
+		&i.Kind,
+		&i.OriginalID,
+		&i.EditedAt,
+		&i.SearchVector,
 	)
 	return i, err
 }
@@ -55,8 +79,26 @@ func (q *Queries) DeleteChirp(ctx context.Context, arg DeleteChirpParams) error
 	return err
 }
 
+const deleteRechirp = `-- name: DeleteRechirp :execrows
+DELETE FROM chirps
+WHERE user_id = $1 AND original_id = $2::UUID AND kind = 'rechirp'
+`
+
+type DeleteRechirpParams struct {
+	UserID     uuid.UUID
+	OriginalID uuid.UUID
+}
+
+func (q *Queries) DeleteRechirp(ctx context.Context, arg DeleteRechirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteRechirp, arg.UserID, arg.OriginalID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getChirpById = `-- name: GetChirpById :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps WHERE id = $1
+SELECT id, created_at, updated_at, body, user_id, parent_id, root_id, like_count, kind, original_id, edited_at, search_vector FROM chirps WHERE id = $1
 `
 
 func (q *Queries) GetChirpById(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -68,28 +110,220 @@ func (q *Queries) GetChirpById(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.ParentID,
+		&i.RootID,
+		&i.LikeCount,
+		&i.Kind,
+		&i.OriginalID,
+		&i.EditedAt,
+		&i.SearchVector,
+	)
+	return i, err
+}
+
+const getChirpRate = `-- name: GetChirpRate :one
+SELECT COUNT(*)::INT AS chirp_count,
+    COALESCE(CEIL(EXTRACT(EPOCH FROM MIN(created_at) + INTERVAL '1 hour' - NOW())), 0)::INT AS retry_after
+FROM chirps
+WHERE user_id = $1
+  AND kind <> 'rechirp'
+  AND created_at > NOW() - INTERVAL '1 hour'
+`
+
+type GetChirpRateRow struct {
+	ChirpCount int32
+	RetryAfter int32
+}
+
+func (q *Queries) GetChirpRate(ctx context.Context, userID uuid.UUID) (GetChirpRateRow, error) {
+	row := q.db.QueryRowContext(ctx, getChirpRate, userID)
+	var i GetChirpRateRow
+	err := row.Scan(
+		&i.ChirpCount,
+		&i.RetryAfter,
 	)
 	return i, err
 }
 
-const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps 
-WHERE $1::TEXT = '' OR user_id::TEXT = $1
-ORDER BY CASE 
-            WHEN $2::TEXT = 'desc' THEN created_at
-            END DESC,
-        CASE
-            WHEN $2 IS NULL OR $2::TEXT = 'asc' THEN created_at
-            END ASC
+const getChirpsByIDs = `-- name: GetChirpsByIDs :many
+SELECT id, created_at, updated_at, body, user_id, parent_id, root_id, like_count, kind, original_id, edited_at, search_vector FROM chirps WHERE id = ANY($1::UUID[])
+`
+
+func (q *Queries) GetChirpsByIDs(ctx context.Context, ids []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ParentID,
+			&i.RootID,
+			&i.LikeCount,
+			&i.Kind,
+			&i.OriginalID,
+			&i.EditedAt,
+			&i.SearchVector,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsPageAsc = `-- name: GetChirpsPageAsc :many
+SELECT id, created_at, updated_at, body, user_id, parent_id, root_id, like_count, kind, original_id, edited_at, search_vector FROM chirps
+WHERE ($1::TEXT = '' OR user_id::TEXT = $1)
+  AND ($2::TIMESTAMP IS NULL
+       OR (created_at, id) > ($2::TIMESTAMP, $3::UUID))
+ORDER BY created_at ASC, id ASC
+LIMIT $4
+`
+
+type GetChirpsPageAscParams struct {
+	AuthorID        string
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageSize        int32
+}
+
+func (q *Queries) GetChirpsPageAsc(ctx context.Context, arg GetChirpsPageAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsPageAsc,
+		arg.AuthorID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ParentID,
+			&i.RootID,
+			&i.LikeCount,
+			&i.Kind,
+			&i.OriginalID,
+			&i.EditedAt,
+			&i.SearchVector,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsPageDesc = `-- name: GetChirpsPageDesc :many
+SELECT id, created_at, updated_at, body, user_id, parent_id, root_id, like_count, kind, original_id, edited_at, search_vector FROM chirps
+WHERE ($1::TEXT = '' OR user_id::TEXT = $1)
+  AND ($2::TIMESTAMP IS NULL
+       OR (created_at, id) < ($2::TIMESTAMP, $3::UUID))
+ORDER BY created_at DESC, id DESC
+LIMIT $4
+`
+
+type GetChirpsPageDescParams struct {
+	AuthorID        string
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageSize        int32
+}
+
+func (q *Queries) GetChirpsPageDesc(ctx context.Context, arg GetChirpsPageDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsPageDesc,
+		arg.AuthorID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ParentID,
+			&i.RootID,
+			&i.LikeCount,
+			&i.Kind,
+			&i.OriginalID,
+			&i.EditedAt,
+			&i.SearchVector,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsPagePopular = `-- name: GetChirpsPagePopular :many
+SELECT id, created_at, updated_at, body, user_id, parent_id, root_id, like_count, kind, original_id, edited_at, search_vector FROM chirps
+WHERE ($1::TEXT = '' OR user_id::TEXT = $1)
+  AND ($2::INT IS NULL
+       OR (like_count, created_at, id) < ($2::INT, $3::TIMESTAMP, $4::UUID))
+ORDER BY like_count DESC, created_at DESC, id DESC
+LIMIT $5
 `
 
-type GetChirpsParams struct {
-	Userid string
-	Sort   string
+type GetChirpsPagePopularParams struct {
+	AuthorID        string
+	CursorLikeCount sql.NullInt32
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	PageSize        int32
 }
 
-func (q *Queries) GetChirps(ctx context.Context, arg GetChirpsParams) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps, arg.Userid, arg.Sort)
+func (q *Queries) GetChirpsPagePopular(ctx context.Context, arg GetChirpsPagePopularParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsPagePopular,
+		arg.AuthorID,
+		arg.CursorLikeCount,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -103,6 +337,13 @@ func (q *Queries) GetChirps(ctx context.Context, arg GetChirpsParams) ([]Chirp,
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.ParentID,
+			&i.RootID,
+			&i.LikeCount,
+			&i.Kind,
+			&i.OriginalID,
+			&i.EditedAt,
+			&i.SearchVector,
 		); err != nil {
 			return nil, err
 		}
@@ -116,3 +357,114 @@ func (q *Queries) GetChirps(ctx context.Context, arg GetChirpsParams) ([]Chirp,
 	}
 	return items, nil
 }
+
+const getThread = `-- name: GetThread :many
+SELECT id, created_at, updated_at, body, user_id, parent_id, root_id, like_count, kind, original_id, edited_at, search_vector FROM chirps
+WHERE id = $1 OR root_id = $1
+ORDER BY created_at ASC, id ASC
+`
+
+func (q *Queries) GetThread(ctx context.Context, id uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getThread, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ParentID,
+			&i.RootID,
+			&i.LikeCount,
+			&i.Kind,
+			&i.OriginalID,
+			&i.EditedAt,
+			&i.SearchVector,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const promoteThreadReplies = `-- name: PromoteThreadReplies :exec
+WITH RECURSIVE subtree AS (
+    SELECT id, id AS new_root_id FROM chirps
+    WHERE root_id = $1::UUID AND parent_id IS NULL
+    UNION ALL
+    SELECT c.id, s.new_root_id FROM chirps c
+    JOIN subtree s ON c.parent_id = s.id
+)
+UPDATE chirps
+SET root_id = NULLIF(subtree.new_root_id, chirps.id)
+FROM subtree
+WHERE chirps.id = subtree.id
+`
+
+func (q *Queries) PromoteThreadReplies(ctx context.Context, oldRootID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, promoteThreadReplies, oldRootID)
+	return err
+}
+
+const reparentReplies = `-- name: ReparentReplies :exec
+UPDATE chirps
+SET parent_id = $1
+WHERE parent_id = $2::UUID
+`
+
+type ReparentRepliesParams struct {
+	NewParentID uuid.NullUUID
+	OldParentID uuid.UUID
+}
+
+func (q *Queries) ReparentReplies(ctx context.Context, arg ReparentRepliesParams) error {
+	_, err := q.db.ExecContext(ctx, reparentReplies, arg.NewParentID, arg.OldParentID)
+	return err
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2,
+    updated_at = NOW(),
+    edited_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, parent_id, root_id, like_count, kind, original_id, edited_at, search_vector
+`
+
+type UpdateChirpBodyParams struct {
+	ID   uuid.UUID
+	Body string
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.ID, arg.Body)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.ParentID,
+		&i.RootID,
+		&i.LikeCount,
+		&i.Kind,
+		&i.OriginalID,
+		&i.EditedAt,
+		&i.SearchVector,
+	)
+	return i, err
+}
@@ -0,0 +1,61 @@
+package mailer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends transactional email. Handlers only ever see this interface so a
+// real provider can be swapped in without touching them.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// OutboxMailer writes every message to its own .eml file in a directory
+// instead of sending it, which is enough for development and tests.
+type OutboxMailer struct {
+	dir  string
+	from string
+}
+
+func NewOutboxMailer(dir, from string) (*OutboxMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &OutboxMailer{dir: dir, from: from}, nil
+}
+
+func (m *OutboxMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	name := fmt.Sprintf("%s-%s.eml", now.Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+
+	data := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\n\r\n%s\r\n",
+		headerValue(m.from), headerValue(msg.To), headerValue(msg.Subject), now.Format(time.RFC1123Z), msg.Body)
+
+	return os.WriteFile(filepath.Join(m.dir, name), []byte(data), 0o600)
+}
+
+// headerValue keeps a value from smuggling extra headers into the message.
+func headerValue(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
@@ -0,0 +1,49 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestOutboxMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+
+	m, err := NewOutboxMailer(dir, "chirpy@localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := Message{
+		To:      "user@example.com",
+		Subject: "Hello",
+		Body:    "token: abc",
+	}
+
+	for range 2 {
+		if err := m.Send(context.Background(), msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(paths), 2)
+
+	data, err := os.ReadFile(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := string(data)
+	assert.Equal(t, strings.Contains(content, "From: chirpy@localhost\r\n"), true)
+	assert.Equal(t, strings.Contains(content, "To: user@example.com\r\n"), true)
+	assert.Equal(t, strings.Contains(content, "Subject: Hello\r\n"), true)
+	assert.Equal(t, strings.HasSuffix(content, "\r\n\r\ntoken: abc\r\n"), true)
+}
@@ -0,0 +1,183 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/aarondever/chirpy/internal/auth"
+)
+
+// Headers sent with every delivery. Receivers check the signature with
+// auth.VerifyWebhookSignature and can use the delivery id to drop retries
+// they have already handled.
+const (
+	DeliveryHeader  = "X-Chirpy-Delivery"
+	EventHeader     = "X-Chirpy-Event"
+	TimestampHeader = "X-Chirpy-Timestamp"
+	SignatureHeader = "X-Chirpy-Signature"
+)
+
+// maxResponseBody is how much of a receiver's response is read before the
+// connection is let go; its content is never used.
+const maxResponseBody = 64 << 10
+
+type Delivery struct {
+	ID        string
+	EventType string
+	URL       string
+	Secret    string
+	Payload   []byte
+}
+
+// Result is the outcome of one attempt. StatusCode is zero when no response
+// came back at all.
+type Result struct {
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+}
+
+func (r Result) OK() bool {
+	return r.Err == nil
+}
+
+// ErrBlockedAddress is returned when an endpoint resolves to an address on
+// the server's own network.
+var ErrBlockedAddress = errors.New("webhook endpoint resolves to a blocked address")
+
+// IsPublicIP reports whether ip is somewhere a delivery may be sent. Loopback,
+// private, link-local and unspecified addresses all reach the server's own
+// network rather than the endpoint owner's.
+func IsPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsUnspecified()
+}
+
+// Sender posts signed deliveries to their endpoints.
+type Sender struct {
+	client       *http.Client
+	allowPrivate bool
+}
+
+type Option func(*Sender)
+
+// AllowPrivateNetworks lets deliveries reach any address, for tests that
+// receive on loopback.
+func AllowPrivateNetworks() Option {
+	return func(s *Sender) {
+		s.allowPrivate = true
+	}
+}
+
+func NewSender(timeout time.Duration, opts ...Option) *Sender {
+	s := &Sender{}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	// the address is checked after it has been resolved, so a hostname that
+	// passed validation can't later be pointed at the server's own network
+	dialer := &net.Dialer{Timeout: timeout, Control: s.checkAddress}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	s.client = &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		// a redirect could send the signed payload somewhere the
+		// endpoint's owner never registered
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return s
+}
+
+func (s *Sender) checkAddress(network, address string, _ syscall.RawConn) error {
+	if s.allowPrivate {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !IsPublicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+	}
+	return nil
+}
+
+// Send makes a single attempt. Anything other than a 2xx response counts as a
+// failure.
+func (s *Sender) Send(ctx context.Context, d Delivery) Result {
+	start := time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return Result{Err: err}
+	}
+
+	ts := start.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(DeliveryHeader, d.ID)
+	req.Header.Set(EventHeader, d.EventType)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(ts, 10))
+	req.Header.Set(SignatureHeader, auth.SignWebhook(d.Secret, ts, d.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return Result{Duration: time.Since(start), Err: err}
+	}
+	defer resp.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+
+	result := Result{StatusCode: resp.StatusCode, Duration: time.Since(start)}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		result.Err = fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return result
+}
+
+// RetryPolicy retries a failed delivery after baseDelay, doubling the wait
+// with each further failure up to maxDelay, and gives up after maxAttempts.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// Next is how long to wait after the given number of failed attempts, or
+// false once the delivery should be given up on.
+func (p RetryPolicy) Next(attempts int) (time.Duration, bool) {
+	if attempts >= p.MaxAttempts {
+		return 0, false
+	}
+
+	delay := p.BaseDelay
+	for range attempts - 1 {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay, true
+		}
+	}
+
+	return delay, true
+}
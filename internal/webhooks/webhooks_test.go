@@ -0,0 +1,145 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aarondever/chirpy/internal/auth"
+	"github.com/go-playground/assert/v2"
+)
+
+func TestSenderSend(t *testing.T) {
+	var gotEvent, gotDelivery string
+	var gotErr error
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+
+		gotEvent = r.Header.Get(EventHeader)
+		gotDelivery = r.Header.Get(DeliveryHeader)
+		gotErr = auth.VerifyWebhookSignature(
+			[]string{"secret"},
+			r.Header.Get(TimestampHeader),
+			r.Header.Get(SignatureHeader),
+			body,
+			time.Now(),
+			time.Minute,
+		)
+
+		switch r.URL.Path {
+		case "/fail":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/redirect":
+			http.Redirect(w, r, "/ok", http.StatusTemporaryRedirect)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer receiver.Close()
+
+	sender := NewSender(5*time.Second, AllowPrivateNetworks())
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantOK     bool
+	}{
+		{name: "accepted", path: "/ok", wantStatus: http.StatusNoContent, wantOK: true},
+		{name: "server error", path: "/fail", wantStatus: http.StatusInternalServerError},
+		{name: "redirect not followed", path: "/redirect", wantStatus: http.StatusTemporaryRedirect},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := sender.Send(context.Background(), Delivery{
+				ID:        "delivery-1",
+				EventType: "chirp.created",
+				URL:       receiver.URL + test.path,
+				Secret:    "secret",
+				Payload:   []byte(`{"type":"chirp.created"}`),
+			})
+
+			assert.Equal(t, result.StatusCode, test.wantStatus)
+			assert.Equal(t, result.OK(), test.wantOK)
+			assert.Equal(t, gotErr, nil)
+			assert.Equal(t, gotEvent, "chirp.created")
+			assert.Equal(t, gotDelivery, "delivery-1")
+		})
+	}
+
+	t.Run("unreachable", func(t *testing.T) {
+		closed := httptest.NewServer(http.NotFoundHandler())
+		closed.Close()
+
+		result := sender.Send(context.Background(), Delivery{URL: closed.URL, Secret: "secret"})
+		assert.Equal(t, result.StatusCode, 0)
+		assert.Equal(t, result.OK(), false)
+	})
+}
+
+func TestSenderBlocksPrivateNetworks(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("delivery reached a loopback receiver")
+	}))
+	defer receiver.Close()
+
+	result := NewSender(5*time.Second).Send(context.Background(), Delivery{URL: receiver.URL, Secret: "secret"})
+	assert.Equal(t, result.StatusCode, 0)
+	assert.Equal(t, errors.Is(result.Err, ErrBlockedAddress), true)
+}
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "93.184.216.34", want: true},
+		{ip: "2606:2800:220:1:248:1893:25c8:1946", want: true},
+		{ip: "127.0.0.1", want: false},
+		{ip: "::1", want: false},
+		{ip: "10.0.0.1", want: false},
+		{ip: "172.16.0.1", want: false},
+		{ip: "192.168.1.1", want: false},
+		{ip: "fd00::1", want: false},
+		{ip: "169.254.169.254", want: false},
+		{ip: "fe80::1", want: false},
+		{ip: "0.0.0.0", want: false},
+		{ip: "::", want: false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, IsPublicIP(net.ParseIP(test.ip)), test.want)
+	}
+}
+
+func TestRetryPolicyNext(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Minute, MaxDelay: 5 * time.Minute}
+
+	tests := []struct {
+		attempts  int
+		wantDelay time.Duration
+		wantRetry bool
+	}{
+		{attempts: 1, wantDelay: time.Minute, wantRetry: true},
+		{attempts: 2, wantDelay: 2 * time.Minute, wantRetry: true},
+		{attempts: 3, wantDelay: 4 * time.Minute, wantRetry: true},
+		{attempts: 4, wantDelay: 5 * time.Minute, wantRetry: true},
+		{attempts: 5, wantDelay: 0, wantRetry: false},
+	}
+
+	for _, test := range tests {
+		delay, retry := policy.Next(test.attempts)
+		assert.Equal(t, delay, test.wantDelay)
+		assert.Equal(t, retry, test.wantRetry)
+	}
+}
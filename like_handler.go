@@ -0,0 +1,81 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/aarondever/chirpy/internal/database"
+	"github.com/aarondever/chirpy/internal/utils"
+	"github.com/google/uuid"
+)
+
+func (cfg *apiConfig) handleLikeChirp(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.getUserFromToken(r, scopeChirpsWrite)
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	chirp, ok := cfg.getPathChirp(w, r)
+	if !ok {
+		return
+	}
+
+	if err := cfg.dbQueries.LikeChirp(r.Context(), database.LikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirp.ID,
+	}); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleUnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.getUserFromToken(r, scopeChirpsWrite)
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	chirp, ok := cfg.getPathChirp(w, r)
+	if !ok {
+		return
+	}
+
+	if err := cfg.dbQueries.UnlikeChirp(r.Context(), database.UnlikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirp.ID,
+	}); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getPathChirp loads the chirp named by the {chirpID} path value, writing the
+// error response itself when it can't.
+func (cfg *apiConfig) getPathChirp(w http.ResponseWriter, r *http.Request) (database.Chirp, bool) {
+	v := r.PathValue("chirpID")
+	chirpID, err := uuid.Parse(v)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return database.Chirp{}, false
+	}
+
+	chirp, err := cfg.dbQueries.GetChirpById(r.Context(), chirpID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, r, "Chirp not found", http.StatusNotFound)
+			return database.Chirp{}, false
+		}
+
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return database.Chirp{}, false
+	}
+
+	return chirp, true
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aarondever/chirpy/internal/database"
+	"github.com/aarondever/chirpy/internal/utils"
+)
+
+const (
+	throttleScopeAccount = "account"
+	throttleScopeIP      = "ip"
+)
+
+// throttlePolicy locks a login key out once it passes freeAttempts failures,
+// doubling the lockout with each further failure. Failures are forgotten an
+// hour after the last one.
+type throttlePolicy struct {
+	freeAttempts int32
+	baseDelay    time.Duration
+	maxDelay     time.Duration
+}
+
+var (
+	accountThrottle = throttlePolicy{freeAttempts: 5, baseDelay: 30 * time.Second, maxDelay: time.Hour}
+	// an IP can sit in front of many users, so it gets more room
+	ipThrottle = throttlePolicy{freeAttempts: 20, baseDelay: 30 * time.Second, maxDelay: time.Hour}
+)
+
+func (p throttlePolicy) lockout(failures int32) time.Duration {
+	if failures < p.freeAttempts {
+		return 0
+	}
+
+	delay := p.baseDelay
+	for range failures - p.freeAttempts {
+		delay *= 2
+		if delay >= p.maxDelay {
+			return p.maxDelay
+		}
+	}
+
+	return delay
+}
+
+func (cfg *apiConfig) handleUnlockUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.getPathUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := cfg.dbQueries.ClearLoginFailures(r.Context(), database.ClearLoginFailuresParams{
+		Scope: throttleScopeAccount,
+		Key:   loginAccountKey(user.Email),
+	}); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkLoginThrottle writes a 429 and reports false while either the account
+// or the client's IP is locked out.
+func (cfg *apiConfig) checkLoginThrottle(w http.ResponseWriter, r *http.Request, email string) bool {
+	retryAfter, err := cfg.dbQueries.GetLoginLockout(r.Context(), database.GetLoginLockoutParams{
+		Account: loginAccountKey(email),
+		Ip:      clientIP(r),
+	})
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter)))
+		utils.RespondWithError(w, r, "Too many failed login attempts, try again later", http.StatusTooManyRequests)
+		return false
+	}
+
+	return true
+}
+
+// recordLoginFailure counts a failed attempt against both the account and the
+// IP, locking out whichever has gone over its policy.
+func (cfg *apiConfig) recordLoginFailure(r *http.Request, email string) error {
+	keys := []struct {
+		scope  string
+		key    string
+		policy throttlePolicy
+	}{
+		{throttleScopeAccount, loginAccountKey(email), accountThrottle},
+		{throttleScopeIP, clientIP(r), ipThrottle},
+	}
+
+	for _, k := range keys {
+		failures, err := cfg.dbQueries.RecordLoginFailure(r.Context(), database.RecordLoginFailureParams{
+			Scope: k.scope,
+			Key:   k.key,
+		})
+		if err != nil {
+			return err
+		}
+
+		lockout := k.policy.lockout(failures)
+		if lockout == 0 {
+			continue
+		}
+
+		if err := cfg.dbQueries.LockLogin(r.Context(), database.LockLoginParams{
+			LockSeconds: int32(lockout / time.Second),
+			Scope:       k.scope,
+			Key:         k.key,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// clearLoginFailures forgets an account's failures after a successful login.
+// The IP's are kept, or one known password would reset them for everyone.
+func (cfg *apiConfig) clearLoginFailures(ctx context.Context, email string) error {
+	return cfg.dbQueries.ClearLoginFailures(ctx, database.ClearLoginFailuresParams{
+		Scope: throttleScopeAccount,
+		Key:   loginAccountKey(email),
+	})
+}
+
+func loginAccountKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
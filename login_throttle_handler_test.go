@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestThrottlePolicyLockout(t *testing.T) {
+	policy := throttlePolicy{freeAttempts: 5, baseDelay: 30 * time.Second, maxDelay: time.Hour}
+
+	tests := []struct {
+		failures int32
+		want     time.Duration
+	}{
+		{failures: 0, want: 0},
+		{failures: 4, want: 0},
+		{failures: 5, want: 30 * time.Second},
+		{failures: 6, want: time.Minute},
+		{failures: 7, want: 2 * time.Minute},
+		{failures: 11, want: 32 * time.Minute},
+		{failures: 12, want: time.Hour},
+		{failures: 1000, want: time.Hour},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%d failures", test.failures), func(t *testing.T) {
+			assert.Equal(t, policy.lockout(test.failures), test.want)
+		})
+	}
+}
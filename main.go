@@ -1,13 +1,20 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"sync/atomic"
+	"time"
 
+	"github.com/aarondever/chirpy/internal/auth"
 	"github.com/aarondever/chirpy/internal/database"
+	"github.com/aarondever/chirpy/internal/mailer"
+	"github.com/aarondever/chirpy/internal/webhooks"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
 )
@@ -20,14 +27,45 @@ func main() {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
+	jwtKeys, err := loadJWTKeys()
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+
+	mail, err := mailer.NewOutboxMailer(envOr("MAIL_OUTBOX_DIR", "outbox"), envOr("MAIL_FROM", "no-reply@chirpy.local"))
+	if err != nil {
+		log.Fatalf("Failed to set up mailer: %v", err)
+	}
+
+	freePlan, err := loadEntitlements("", defaultFreePlan)
+	if err != nil {
+		log.Fatalf("Failed to load free plan: %v", err)
+	}
+
+	redPlan, err := loadEntitlements("RED_", defaultRedPlan)
+	if err != nil {
+		log.Fatalf("Failed to load Chirpy Red plan: %v", err)
+	}
+
 	cfg := apiConfig{
-		fileserverHits: atomic.Int32{},
-		platform:       os.Getenv("PLATFORM"),
-		dbQueries:      database.New(db),
-		jwtSecret:      os.Getenv("JWT_SECRET"),
-		polkaKey:       os.Getenv("POLKA_KEY"),
+		fileserverHits:            atomic.Int32{},
+		platform:                  os.Getenv("PLATFORM"),
+		db:                        db,
+		dbQueries:                 database.New(db),
+		jwtKeys:                   jwtKeys,
+		polkaKeys:                 []string{os.Getenv("POLKA_KEY"), os.Getenv("POLKA_KEY_PREVIOUS")},
+		mailer:                    mail,
+		baseURL:                   envOr("BASE_URL", "http://localhost:8080"),
+		emailVerificationRequired: os.Getenv("REQUIRE_EMAIL_VERIFICATION") == "true",
+		freePlan:                  freePlan,
+		redPlan:                   redPlan,
+		webhookSender:             webhooks.NewSender(webhookDeliveryTimeout),
 	}
 
+	go runEvery(context.Background(), subscriptionExpiryInterval, "expire subscriptions", cfg.expireSubscriptions)
+	go runEvery(context.Background(), scheduledChirpInterval, "publish scheduled chirps", cfg.publishScheduledChirps)
+	go runEvery(context.Background(), webhookDeliveryInterval, "deliver webhooks", cfg.deliverWebhooks)
+
 	serverMux := http.NewServeMux()
 
 	// app enpoints
@@ -36,21 +74,67 @@ func main() {
 			http.StripPrefix("/app",
 				http.FileServer(http.Dir(".")))))
 
-	// admin enpoints
-	serverMux.HandleFunc("GET /admin/metrics", cfg.handleMetrics)
-	serverMux.HandleFunc("POST /admin/reset", cfg.resetMetrics)
+	// admin enpoints, all behind an admin login
+	adminMux := http.NewServeMux()
+	adminMux.HandleFunc("GET /admin/metrics", cfg.handleMetrics)
+	adminMux.HandleFunc("POST /admin/reset", cfg.resetMetrics)
+	adminMux.HandleFunc("POST /admin/users/{userID}/unlock", cfg.handleUnlockUser)
+	adminMux.HandleFunc("PUT /admin/users/{userID}/role", cfg.handleSetUserRole)
+	adminMux.HandleFunc("GET /admin/webhooks/events", cfg.handleGetWebhookEvents)
+	adminMux.HandleFunc("POST /admin/webhooks/events/{eventID}/replay", cfg.handleReplayWebhookEvent)
+	serverMux.Handle("/admin/", cfg.requireRole(roleAdmin, adminMux))
+
+	serverMux.HandleFunc("GET /.well-known/jwks.json", cfg.handleJWKS)
 
 	// api enpoints
 	serverMux.HandleFunc("GET /api/healthz", handleReadiness)
 	serverMux.HandleFunc("GET /api/chirps", cfg.handleGetChirps)
 	serverMux.HandleFunc("GET /api/chirps/{chirpID}", cfg.handleGetChirpByID)
 	serverMux.HandleFunc("POST /api/chirps", cfg.handleCreateChirp)
+	serverMux.HandleFunc("PATCH /api/chirps/{chirpID}", cfg.handleUpdateChirp)
 	serverMux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.handleDeleteChirp)
+	serverMux.HandleFunc("GET /api/chirps/{chirpID}/revisions", cfg.handleGetChirpRevisions)
+	serverMux.HandleFunc("GET /api/chirps/{chirpID}/thread", cfg.handleGetThread)
+	serverMux.HandleFunc("PUT /api/chirps/{chirpID}/like", cfg.handleLikeChirp)
+	serverMux.HandleFunc("DELETE /api/chirps/{chirpID}/like", cfg.handleUnlikeChirp)
+	serverMux.HandleFunc("POST /api/chirps/{chirpID}/rechirp", cfg.handleRechirp)
+	serverMux.HandleFunc("DELETE /api/chirps/{chirpID}/rechirp", cfg.handleUndoRechirp)
+	serverMux.HandleFunc("POST /api/scheduled-chirps", cfg.handleCreateScheduledChirp)
+	serverMux.HandleFunc("GET /api/scheduled-chirps", cfg.handleGetScheduledChirps)
+	serverMux.HandleFunc("DELETE /api/scheduled-chirps/{scheduledID}", cfg.handleDeleteScheduledChirp)
 	serverMux.HandleFunc("POST /api/users", cfg.handleCreateUser)
 	serverMux.HandleFunc("PUT /api/users", cfg.handleUpdateUser)
+	serverMux.HandleFunc("GET /api/users/{handle}", cfg.handleGetProfile)
+	serverMux.HandleFunc("POST /api/users/{userID}/follow", cfg.handleFollowUser)
+	serverMux.HandleFunc("DELETE /api/users/{userID}/follow", cfg.handleUnfollowUser)
+	serverMux.HandleFunc("GET /api/users/{userID}/followers", cfg.handleGetFollowers)
+	serverMux.HandleFunc("GET /api/users/{userID}/following", cfg.handleGetFollowing)
+	serverMux.HandleFunc("GET /api/timeline", cfg.handleGetTimeline)
+	serverMux.HandleFunc("GET /api/mentions", cfg.handleGetMentions)
+	serverMux.HandleFunc("GET /api/search", cfg.handleSearchChirps)
+	serverMux.HandleFunc("GET /api/hashtags/trending", cfg.handleGetTrendingHashtags)
+	serverMux.HandleFunc("GET /api/hashtags/{tag}/chirps", cfg.handleGetHashtagChirps)
 	serverMux.HandleFunc("POST /api/login", cfg.handleLogin)
+	serverMux.HandleFunc("POST /api/login/2fa", cfg.handleLoginTwoFactor)
+	serverMux.HandleFunc("POST /api/2fa/enroll", cfg.handleEnrollTwoFactor)
+	serverMux.HandleFunc("POST /api/2fa/confirm", cfg.handleConfirmTwoFactor)
+	serverMux.HandleFunc("DELETE /api/2fa", cfg.handleDisableTwoFactor)
 	serverMux.HandleFunc("POST /api/refresh", cfg.handleRefreshToken)
 	serverMux.HandleFunc("POST /api/revoke", cfg.handleRevokeToken)
+	serverMux.HandleFunc("POST /api/password-reset/request", cfg.handleRequestPasswordReset)
+	serverMux.HandleFunc("POST /api/password-reset/confirm", cfg.handleConfirmPasswordReset)
+	serverMux.HandleFunc("GET /api/verify-email", cfg.handleVerifyEmail)
+	serverMux.HandleFunc("POST /api/verify-email/resend", cfg.handleResendVerificationEmail)
+	serverMux.HandleFunc("GET /api/sessions", cfg.handleGetSessions)
+	serverMux.HandleFunc("DELETE /api/sessions", cfg.handleDeleteSessions)
+	serverMux.HandleFunc("DELETE /api/sessions/{sessionID}", cfg.handleDeleteSession)
+	serverMux.HandleFunc("POST /api/tokens", cfg.handleCreateAccessToken)
+	serverMux.HandleFunc("GET /api/tokens", cfg.handleGetAccessTokens)
+	serverMux.HandleFunc("DELETE /api/tokens/{tokenID}", cfg.handleRevokeAccessToken)
+	serverMux.HandleFunc("POST /api/webhooks", cfg.handleCreateWebhookEndpoint)
+	serverMux.HandleFunc("GET /api/webhooks", cfg.handleGetWebhookEndpoints)
+	serverMux.HandleFunc("DELETE /api/webhooks/{endpointID}", cfg.handleDeleteWebhookEndpoint)
+	serverMux.HandleFunc("GET /api/webhooks/{endpointID}/deliveries", cfg.handleGetWebhookDeliveries)
 	serverMux.HandleFunc("POST /api/polka/webhooks", cfg.polkaWebhook)
 
 	server := http.Server{
@@ -68,3 +152,55 @@ func handleReadiness(w http.ResponseWriter, r *http.Request) {
 	body := []byte("OK")
 	w.Write(body)
 }
+
+// loadJWTKeys signs with the keys in JWT_KEYS_DIR when it is set, still
+// accepting tokens made with JWT_SECRET; otherwise JWT_SECRET signs with HS256.
+// JWT_ISSUER, JWT_AUDIENCE, JWT_ALGORITHMS and JWT_LEEWAY tighten validation.
+func loadJWTKeys() (*auth.KeySet, error) {
+	secret := os.Getenv("JWT_SECRET")
+
+	keys := auth.NewHMACKeySet(secret)
+	if dir := os.Getenv("JWT_KEYS_DIR"); dir != "" {
+		var err error
+		keys, err = auth.LoadKeySet(dir, os.Getenv("JWT_SIGNING_KEY_ID"))
+		if err != nil {
+			return nil, err
+		}
+
+		if secret != "" {
+			keys.AddHMACSecret(secret)
+		}
+	}
+
+	opts := auth.TokenOptions{
+		Issuer:   envOr("JWT_ISSUER", "chirpy"),
+		Audience: os.Getenv("JWT_AUDIENCE"),
+	}
+
+	for alg := range strings.SplitSeq(os.Getenv("JWT_ALGORITHMS"), ",") {
+		if alg = strings.TrimSpace(alg); alg != "" {
+			opts.Algorithms = append(opts.Algorithms, alg)
+		}
+	}
+
+	if v := os.Getenv("JWT_LEEWAY"); v != "" {
+		leeway, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("JWT_LEEWAY: %w", err)
+		}
+		opts.Leeway = leeway
+	}
+
+	if err := keys.SetOptions(opts); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
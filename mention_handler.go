@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aarondever/chirpy/internal/database"
+	"github.com/aarondever/chirpy/internal/utils"
+	"github.com/google/uuid"
+)
+
+var mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@])@([A-Za-z0-9_]{1,15})\b`)
+
+// mentionResponse locates an @handle in a chirp body. Start and End are
+// character (not byte) offsets, End exclusive, and include the "@".
+type mentionResponse struct {
+	UserID uuid.UUID `json:"user_id"`
+	Start  int32     `json:"start"`
+	End    int32     `json:"end"`
+}
+
+type mention struct {
+	handle string
+	start  int32
+	end    int32
+}
+
+func (cfg *apiConfig) handleGetMentions(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.getUserFromToken(r, scopeChirpsRead)
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	page, err := parsePageParams(r.URL.Query())
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	chirps, err := cfg.dbQueries.GetMentionChirps(r.Context(), database.GetMentionChirpsParams{
+		UserID:          userID,
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		PageSize:        page.fetchSize(),
+	})
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	chirps, nextCursor := trimPage(page, chirps, chirpCursorOf)
+
+	cfg.respondWithChirpPage(w, r, uuid.NullUUID{UUID: userID, Valid: true}, chirps, nextCursor)
+}
+
+// mentionUsers replaces the mentions recorded for chirp with the @handles in
+// its body that belong to a user. Unknown handles are left as plain text.
+func mentionUsers(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	if err := q.DeleteChirpMentions(ctx, chirp.ID); err != nil {
+		return err
+	}
+
+	mentions := extractMentions(chirp.Body)
+	if len(mentions) == 0 {
+		return nil
+	}
+
+	handles := make([]string, 0, len(mentions))
+	for _, m := range mentions {
+		handles = append(handles, m.handle)
+	}
+
+	users, err := q.GetUsersByHandles(ctx, handles)
+	if err != nil {
+		return err
+	}
+
+	userIDs := make(map[string]uuid.UUID, len(users))
+	for _, user := range users {
+		userIDs[strings.ToLower(user.Handle.String)] = user.ID
+	}
+
+	for _, m := range mentions {
+		userID, ok := userIDs[m.handle]
+		if !ok {
+			continue
+		}
+
+		if err := q.CreateMention(ctx, database.CreateMentionParams{
+			ChirpID:     chirp.ID,
+			UserID:      userID,
+			StartOffset: m.start,
+			EndOffset:   m.end,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func extractMentions(body string) []mention {
+	var mentions []mention
+	for _, loc := range mentionPattern.FindAllStringSubmatchIndex(body, -1) {
+		// loc[2]:loc[3] is the handle, the "@" sits right before it
+		start := loc[2] - 1
+		mentions = append(mentions, mention{
+			handle: strings.ToLower(body[loc[2]:loc[3]]),
+			start:  int32(utf8.RuneCountInString(body[:start])),
+			end:    int32(utf8.RuneCountInString(body[:loc[3]])),
+		})
+	}
+
+	return mentions
+}
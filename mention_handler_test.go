@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-playground/assert/v2"
+)
+
+func TestExtractMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []mention
+	}{
+		{name: "none", body: "no one here", want: nil},
+		{name: "start of body", body: "@alice hi", want: []mention{{handle: "alice", start: 0, end: 6}}},
+		{name: "lowercased", body: "hi @Alice", want: []mention{{handle: "alice", start: 3, end: 9}}},
+		{name: "punctuation ends handle", body: "thanks @bob!", want: []mention{{handle: "bob", start: 7, end: 11}}},
+		{
+			name: "several",
+			body: "@a and @b_2",
+			want: []mention{{handle: "a", start: 0, end: 2}, {handle: "b_2", start: 7, end: 11}},
+		},
+		{name: "offsets count runes", body: "héllo @bob", want: []mention{{handle: "bob", start: 6, end: 10}}},
+		{name: "email skipped", body: "mail alice@example.com", want: nil},
+		{name: "double at skipped", body: "@@alice", want: nil},
+		{name: "too long skipped", body: "@abcdefghijklmnop", want: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, extractMentions(test.body), test.want)
+		})
+	}
+}
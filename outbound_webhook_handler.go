@@ -0,0 +1,440 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aarondever/chirpy/internal/auth"
+	"github.com/aarondever/chirpy/internal/database"
+	"github.com/aarondever/chirpy/internal/utils"
+	"github.com/aarondever/chirpy/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+// Events an endpoint can subscribe to. Each is about the endpoint owner's own
+// account: chirps they post or delete, and users following them.
+const (
+	eventChirpCreated = "chirp.created"
+	eventChirpDeleted = "chirp.deleted"
+	eventUserFollowed = "user.followed"
+)
+
+var knownWebhookEvents = []string{eventChirpCreated, eventChirpDeleted, eventUserFollowed}
+
+const (
+	maxWebhookEndpoints        = 10
+	webhookDeliveryInterval    = 5 * time.Second
+	webhookDeliveryBatchSize   = 20
+	webhookDeliveryTimeout     = 10 * time.Second
+	defaultWebhookDeliveryPage = 50
+	// webhookDeliveryLease keeps a claimed delivery from being picked up again
+	// while it is being sent; one that is never finished comes back after it
+	webhookDeliveryLease = time.Minute
+)
+
+var webhookRetryPolicy = webhooks.RetryPolicy{
+	MaxAttempts: 8,
+	BaseDelay:   time.Minute,
+	MaxDelay:    6 * time.Hour,
+}
+
+type webhookEndpointResponse struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+}
+
+type webhookDeliveryResponse struct {
+	ID            uuid.UUID                        `json:"id"`
+	CreatedAt     time.Time                        `json:"created_at"`
+	EventType     string                           `json:"event_type"`
+	Payload       json.RawMessage                  `json:"payload"`
+	Status        string                           `json:"status"`
+	Attempts      int32                            `json:"attempts"`
+	NextAttemptAt *time.Time                       `json:"next_attempt_at"`
+	LastError     *string                          `json:"last_error"`
+	DeliveredAt   *time.Time                       `json:"delivered_at"`
+	AttemptLog    []webhookDeliveryAttemptResponse `json:"attempt_log"`
+}
+
+type webhookDeliveryAttemptResponse struct {
+	AttemptedAt time.Time `json:"attempted_at"`
+	StatusCode  *int32    `json:"status_code"`
+	Error       *string   `json:"error"`
+	DurationMs  int32     `json:"duration_ms"`
+}
+
+// webhookPayload is the body of every delivery. ID is shared by the
+// deliveries of one event to different endpoints.
+type webhookPayload struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+type chirpEventData struct {
+	ID        uuid.UUID  `json:"id"`
+	UserID    uuid.UUID  `json:"user_id"`
+	Body      string     `json:"body,omitempty"`
+	Kind      string     `json:"kind,omitempty"`
+	InReplyTo *uuid.UUID `json:"in_reply_to,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+type followEventData struct {
+	UserID     uuid.UUID `json:"user_id"`
+	FollowerID uuid.UUID `json:"follower_id"`
+}
+
+func toWebhookEndpointResponse(endpoint database.WebhookEndpoint) webhookEndpointResponse {
+	return webhookEndpointResponse{
+		ID:        endpoint.ID,
+		CreatedAt: endpoint.CreatedAt,
+		URL:       endpoint.Url,
+		Events:    endpoint.Events,
+	}
+}
+
+// handleCreateWebhookEndpoint returns the signing secret only in this
+// response.
+func (cfg *apiConfig) handleCreateWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.getUserFromToken(r, scopeWebhooksWrite)
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	var body struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateWebhookURL(body.URL); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if len(body.Events) == 0 {
+		utils.RespondWithError(w, r, "At least one event is required", http.StatusBadRequest)
+		return
+	}
+	for _, event := range body.Events {
+		if !slices.Contains(knownWebhookEvents, event) {
+			utils.RespondWithError(w, r, fmt.Sprintf("Unknown event %q", event), http.StatusBadRequest)
+			return
+		}
+	}
+
+	count, err := cfg.dbQueries.CountWebhookEndpoints(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if count >= maxWebhookEndpoints {
+		utils.RespondWithError(w, r, "Too many webhook endpoints", http.StatusForbidden)
+		return
+	}
+
+	secret := auth.MakeRandomToken()
+
+	endpoint, err := cfg.dbQueries.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		UserID: userID,
+		Url:    body.URL,
+		Events: slices.Compact(slices.Sorted(slices.Values(body.Events))),
+		Secret: secret,
+	})
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := toWebhookEndpointResponse(endpoint)
+	response.Secret = endpoint.Secret
+
+	utils.RespondWithJSON(w, r, response, http.StatusCreated)
+}
+
+func (cfg *apiConfig) handleGetWebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.getUserFromToken(r, scopeWebhooksRead)
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	endpoints, err := cfg.dbQueries.GetWebhookEndpoints(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]webhookEndpointResponse, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		response = append(response, toWebhookEndpointResponse(endpoint))
+	}
+
+	utils.RespondWithJSON(w, r, response, http.StatusOK)
+}
+
+// handleDeleteWebhookEndpoint also drops the endpoint's deliveries, including
+// any still waiting to be sent.
+func (cfg *apiConfig) handleDeleteWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.getUserFromToken(r, scopeWebhooksWrite)
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := cfg.dbQueries.DeleteWebhookEndpoint(r.Context(), database.DeleteWebhookEndpointParams{
+		ID:     endpointID,
+		UserID: userID,
+	})
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		utils.RespondWithError(w, r, "Webhook endpoint not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleGetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.getUserFromToken(r, scopeWebhooksRead)
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pageSize := int32(defaultWebhookDeliveryPage)
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			utils.RespondWithError(w, r, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		pageSize = int32(min(limit, maxPageSize))
+	}
+
+	if _, err := cfg.dbQueries.GetWebhookEndpoint(r.Context(), database.GetWebhookEndpointParams{
+		ID:     endpointID,
+		UserID: userID,
+	}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, r, "Webhook endpoint not found", http.StatusNotFound)
+			return
+		}
+
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	deliveries, err := cfg.dbQueries.GetWebhookDeliveries(r.Context(), database.GetWebhookDeliveriesParams{
+		EndpointID: endpointID,
+		PageSize:   pageSize,
+	})
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	deliveryIDs := make([]uuid.UUID, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		deliveryIDs = append(deliveryIDs, delivery.ID)
+	}
+
+	attempts, err := cfg.dbQueries.GetWebhookDeliveryAttempts(r.Context(), deliveryIDs)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	attemptLogs := make(map[uuid.UUID][]webhookDeliveryAttemptResponse)
+	for _, attempt := range attempts {
+		response := webhookDeliveryAttemptResponse{
+			AttemptedAt: attempt.AttemptedAt,
+			DurationMs:  attempt.DurationMs,
+		}
+		if attempt.StatusCode.Valid {
+			response.StatusCode = &attempt.StatusCode.Int32
+		}
+		if attempt.Error.Valid {
+			response.Error = &attempt.Error.String
+		}
+		attemptLogs[attempt.DeliveryID] = append(attemptLogs[attempt.DeliveryID], response)
+	}
+
+	response := make([]webhookDeliveryResponse, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		item := webhookDeliveryResponse{
+			ID:         delivery.ID,
+			CreatedAt:  delivery.CreatedAt,
+			EventType:  delivery.EventType,
+			Payload:    delivery.Payload,
+			Status:     delivery.Status,
+			Attempts:   delivery.Attempts,
+			AttemptLog: attemptLogs[delivery.ID],
+		}
+		if item.AttemptLog == nil {
+			item.AttemptLog = []webhookDeliveryAttemptResponse{}
+		}
+		if delivery.Status == "pending" {
+			item.NextAttemptAt = &delivery.NextAttemptAt
+		}
+		if delivery.LastError.Valid {
+			item.LastError = &delivery.LastError.String
+		}
+		if delivery.DeliveredAt.Valid {
+			item.DeliveredAt = &delivery.DeliveredAt.Time
+		}
+		response = append(response, item)
+	}
+
+	utils.RespondWithJSON(w, r, response, http.StatusOK)
+}
+
+// enqueueWebhookEvent queues a delivery to each of the user's endpoints that
+// subscribes to eventType. Run it in the same transaction as the change it
+// reports so neither happens without the other.
+func enqueueWebhookEvent(ctx context.Context, q *database.Queries, userID uuid.UUID, eventType string, data any) error {
+	payload, err := json.Marshal(webhookPayload{
+		ID:        uuid.New(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = q.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+		EventType: eventType,
+		Payload:   payload,
+		UserID:    userID,
+	})
+	return err
+}
+
+func newChirpEventData(chirp database.Chirp) chirpEventData {
+	data := chirpEventData{
+		ID:        chirp.ID,
+		UserID:    chirp.UserID,
+		Body:      chirp.Body,
+		Kind:      chirp.Kind,
+		CreatedAt: &chirp.CreatedAt,
+	}
+	if chirp.ParentID.Valid {
+		data.InReplyTo = &chirp.ParentID.UUID
+	}
+	return data
+}
+
+// deliverWebhooks sends a batch of due deliveries at once and records how each
+// attempt went.
+func (cfg *apiConfig) deliverWebhooks(ctx context.Context) error {
+	deliveries, err := cfg.dbQueries.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+		LeaseSeconds: int32(webhookDeliveryLease / time.Second),
+		BatchSize:    webhookDeliveryBatchSize,
+	})
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Go(func() {
+			if err := cfg.deliverWebhook(ctx, delivery); err != nil {
+				log.Printf("Failed to record webhook delivery %s: %v", delivery.ID, err)
+			}
+		})
+	}
+	wg.Wait()
+
+	return nil
+}
+
+func (cfg *apiConfig) deliverWebhook(ctx context.Context, delivery database.ClaimWebhookDeliveriesRow) error {
+	result := cfg.webhookSender.Send(ctx, webhooks.Delivery{
+		ID:        delivery.ID.String(),
+		EventType: delivery.EventType,
+		URL:       delivery.Url,
+		Secret:    delivery.Secret,
+		Payload:   delivery.Payload,
+	})
+
+	attempt := database.RecordWebhookDeliveryAttemptParams{
+		DeliveryID: delivery.ID,
+		DurationMs: int32(result.Duration / time.Millisecond),
+	}
+	if result.StatusCode != 0 {
+		attempt.StatusCode = sql.NullInt32{Int32: int32(result.StatusCode), Valid: true}
+	}
+	if result.Err != nil {
+		attempt.Error = sql.NullString{String: result.Err.Error(), Valid: true}
+	}
+
+	if err := cfg.dbQueries.RecordWebhookDeliveryAttempt(ctx, attempt); err != nil {
+		return err
+	}
+
+	if result.OK() {
+		return cfg.dbQueries.MarkWebhookDeliverySucceeded(ctx, delivery.ID)
+	}
+
+	retryAfter, retry := webhookRetryPolicy.Next(int(delivery.Attempts) + 1)
+	return cfg.dbQueries.MarkWebhookDeliveryFailed(ctx, database.MarkWebhookDeliveryFailedParams{
+		LastError:    result.Err.Error(),
+		GiveUp:       !retry,
+		RetrySeconds: int32(retryAfter / time.Second),
+		ID:           delivery.ID,
+	})
+}
+
+func validateWebhookURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+
+	// hostnames are checked again once resolved, when the sender dials them
+	host := u.Hostname()
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return errors.New("url must not point at a private network")
+	}
+	if ip := net.ParseIP(host); ip != nil && !webhooks.IsPublicIP(ip) {
+		return errors.New("url must not point at a private network")
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/aarondever/chirpy/internal/database"
+	"github.com/aarondever/chirpy/internal/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+type chirpPageResponse struct {
+	Chirps     []chirpResponse `json:"chirps"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// pageCursor is the keyset position of the last item on a page. Clients only
+// ever see it base64 encoded. Sort records which ordering the position belongs
+// to, since the same fields mean something else under another one.
+type pageCursor struct {
+	Sort      string    `json:"sort,omitempty"`
+	Rank      float32   `json:"rank,omitempty"`
+	LikeCount int32     `json:"like_count,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ID        uuid.UUID `json:"id"`
+}
+
+type pageParams struct {
+	limit  int32
+	sort   string
+	cursor *pageCursor
+}
+
+func parsePageParams(query url.Values) (pageParams, error) {
+	return parseSortedPageParams(query, "")
+}
+
+// parseSortedPageParams is parsePageParams for lists that can be ordered more
+// than one way. A cursor is only accepted by the ordering that made it.
+func parseSortedPageParams(query url.Values, sort string) (pageParams, error) {
+	params := pageParams{limit: defaultPageSize, sort: sort}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return pageParams{}, errors.New("limit must be a positive integer")
+		}
+		params.limit = int32(min(limit, maxPageSize))
+	}
+
+	if v := query.Get("cursor"); v != "" {
+		cursor, err := decodeCursor(v)
+		if err != nil {
+			return pageParams{}, errors.New("invalid cursor")
+		}
+		if cursor.Sort != sort {
+			return pageParams{}, errors.New("cursor does not match sort")
+		}
+		params.cursor = &cursor
+	}
+
+	return params, nil
+}
+
+// fetchSize asks for one extra row so we know whether another page exists.
+func (p pageParams) fetchSize() int32 {
+	return p.limit + 1
+}
+
+func (p pageParams) cursorRank() sql.NullFloat64 {
+	if p.cursor == nil {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: float64(p.cursor.Rank), Valid: true}
+}
+
+func (p pageParams) cursorLikeCount() sql.NullInt32 {
+	if p.cursor == nil {
+		return sql.NullInt32{}
+	}
+	return sql.NullInt32{Int32: p.cursor.LikeCount, Valid: true}
+}
+
+func (p pageParams) cursorCreatedAt() sql.NullTime {
+	if p.cursor == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: p.cursor.CreatedAt, Valid: true}
+}
+
+func (p pageParams) cursorID() uuid.NullUUID {
+	if p.cursor == nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: p.cursor.ID, Valid: true}
+}
+
+// trimPage drops the extra row requested by fetchSize and returns the cursor
+// for the next page, or an empty string when this is the last page.
+func trimPage[T any](p pageParams, items []T, cursorOf func(T) pageCursor) ([]T, string) {
+	if len(items) <= int(p.limit) {
+		return items, ""
+	}
+
+	items = items[:p.limit]
+
+	cursor := cursorOf(items[len(items)-1])
+	cursor.Sort = p.sort
+	return items, encodeCursor(cursor)
+}
+
+func chirpCursorOf(chirp database.Chirp) pageCursor {
+	return pageCursor{
+		CreatedAt: chirp.CreatedAt,
+		ID:        chirp.ID,
+	}
+}
+
+func popularChirpCursorOf(chirp database.Chirp) pageCursor {
+	cursor := chirpCursorOf(chirp)
+	cursor.LikeCount = chirp.LikeCount
+	return cursor
+}
+
+func (cfg *apiConfig) respondWithChirpPage(w http.ResponseWriter, r *http.Request, viewer uuid.NullUUID, chirps []database.Chirp, nextCursor string) {
+	responses, err := cfg.toChirpResponses(r.Context(), viewer, chirps)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := chirpPageResponse{
+		Chirps:     responses,
+		NextCursor: nextCursor,
+	}
+
+	utils.RespondWithJSON(w, r, response, http.StatusOK)
+}
+
+// setNextPageLink points at the next page with a Link header, for lists that
+// respond with a bare array.
+func setNextPageLink(w http.ResponseWriter, r *http.Request, nextCursor string) {
+	if nextCursor == "" {
+		return
+	}
+
+	next := *r.URL
+	query := next.Query()
+	query.Set("cursor", nextCursor)
+	next.RawQuery = query.Encode()
+
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+}
+
+func encodeCursor(cursor pageCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	var cursor pageCursor
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor, err
+	}
+
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return cursor, err
+	}
+
+	return cursor, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-playground/assert/v2"
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	createdAt := time.Date(2026, 10, 18, 9, 30, 0, 123456000, time.UTC)
+	id := uuid.New()
+
+	tests := []struct {
+		name   string
+		cursor pageCursor
+	}{
+		{name: "unsorted", cursor: pageCursor{CreatedAt: createdAt, ID: id}},
+		{name: "asc", cursor: pageCursor{Sort: "asc", CreatedAt: createdAt, ID: id}},
+		{name: "popular", cursor: pageCursor{Sort: "popular", LikeCount: 42, CreatedAt: createdAt, ID: id}},
+		{name: "popular with no likes", cursor: pageCursor{Sort: "popular", CreatedAt: createdAt, ID: id}},
+		{name: "ranked", cursor: pageCursor{Rank: 0.25, CreatedAt: createdAt, ID: id}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cursor, err := decodeCursor(encodeCursor(test.cursor))
+			assert.Equal(t, err, nil)
+			assert.Equal(t, cursor, test.cursor)
+		})
+	}
+}
+
+func TestParseSortedPageParams(t *testing.T) {
+	cursorFor := func(sort string) string {
+		return encodeCursor(pageCursor{Sort: sort, CreatedAt: time.Now().UTC(), ID: uuid.New()})
+	}
+
+	tests := []struct {
+		name       string
+		sort       string
+		query      url.Values
+		wantErr    bool
+		wantLimit  int32
+		wantCursor bool
+	}{
+		{name: "defaults", sort: "asc", query: url.Values{}, wantLimit: defaultPageSize},
+		{name: "limit capped", sort: "asc", query: url.Values{"limit": {"1000"}}, wantLimit: maxPageSize},
+		{name: "bad limit", sort: "asc", query: url.Values{"limit": {"0"}}, wantErr: true},
+		{name: "matching cursor", sort: "popular", query: url.Values{"cursor": {cursorFor("popular")}}, wantLimit: defaultPageSize, wantCursor: true},
+		{name: "cursor from another sort", sort: "popular", query: url.Values{"cursor": {cursorFor("asc")}}, wantErr: true},
+		{name: "sorted cursor on unsorted list", sort: "", query: url.Values{"cursor": {cursorFor("desc")}}, wantErr: true},
+		{name: "garbage cursor", sort: "asc", query: url.Values{"cursor": {"not-a-cursor"}}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			params, err := parseSortedPageParams(test.query, test.sort)
+			assert.Equal(t, err != nil, test.wantErr)
+			if test.wantErr {
+				return
+			}
+			assert.Equal(t, params.limit, test.wantLimit)
+			assert.Equal(t, params.cursor != nil, test.wantCursor)
+		})
+	}
+}
+
+func TestTrimPageRecordsSort(t *testing.T) {
+	page := pageParams{limit: 2, sort: "desc"}
+	items := []int{1, 2, 3}
+
+	items, next := trimPage(page, items, func(int) pageCursor {
+		return pageCursor{CreatedAt: time.Now().UTC(), ID: uuid.New()}
+	})
+	assert.Equal(t, items, []int{1, 2})
+
+	cursor, err := decodeCursor(next)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, cursor.Sort, "desc")
+}
+
+func TestSetNextPageLink(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/chirps?sort=desc&cursor=old", nil)
+
+	w := httptest.NewRecorder()
+	setNextPageLink(w, r, "")
+	assert.Equal(t, w.Header().Get("Link"), "")
+
+	w = httptest.NewRecorder()
+	setNextPageLink(w, r, "next")
+	assert.Equal(t, w.Header().Get("Link"), `</api/chirps?cursor=next&sort=desc>; rel="next"`)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aarondever/chirpy/internal/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+)
+
+var handlePattern = regexp.MustCompile(`^[A-Za-z0-9_]{1,15}$`)
+
+// reservedHandles would be confusing or misleading as someone's public name.
+var reservedHandles = map[string]bool{
+	"admin":     true,
+	"api":       true,
+	"chirpy":    true,
+	"moderator": true,
+	"support":   true,
+}
+
+type profileResponse struct {
+	ID             uuid.UUID `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	Handle         string    `json:"handle"`
+	DisplayName    string    `json:"display_name"`
+	Bio            string    `json:"bio"`
+	ChirpCount     int64     `json:"chirp_count"`
+	FollowerCount  int64     `json:"follower_count"`
+	FollowingCount int64     `json:"following_count"`
+}
+
+type authorResponse struct {
+	Handle      string `json:"handle"`
+	DisplayName string `json:"display_name"`
+}
+
+func (cfg *apiConfig) handleGetProfile(w http.ResponseWriter, r *http.Request) {
+	profile, err := cfg.dbQueries.GetUserProfile(r.Context(), r.PathValue("handle"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, r, "User not found", http.StatusNotFound)
+			return
+		}
+
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := profileResponse{
+		ID:             profile.ID,
+		CreatedAt:      profile.CreatedAt,
+		Handle:         profile.Handle.String,
+		DisplayName:    profile.DisplayName,
+		Bio:            profile.Bio,
+		ChirpCount:     profile.ChirpCount,
+		FollowerCount:  profile.FollowerCount,
+		FollowingCount: profile.FollowingCount,
+	}
+
+	utils.RespondWithJSON(w, r, response, http.StatusOK)
+}
+
+// validateProfile checks the public profile fields of a user request. Empty
+// or missing fields are valid: they mean "unset" on create and "unchanged"
+// on update.
+func validateProfile(body userRequest) error {
+	if body.Handle != "" {
+		if !handlePattern.MatchString(body.Handle) {
+			return errors.New("Handle must be 1-15 letters, digits or underscores")
+		}
+		if reservedHandles[strings.ToLower(body.Handle)] {
+			return errors.New("Handle is reserved")
+		}
+	}
+
+	if body.DisplayName != nil && utf8.RuneCountInString(*body.DisplayName) > maxDisplayNameLength {
+		return errors.New("Display name is too long")
+	}
+
+	if body.Bio != nil && utf8.RuneCountInString(*body.Bio) > maxBioLength {
+		return errors.New("Bio is too long")
+	}
+
+	return nil
+}
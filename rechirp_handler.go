@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/aarondever/chirpy/internal/database"
+	"github.com/aarondever/chirpy/internal/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	chirpKindChirp   = "chirp"
+	chirpKindRechirp = "rechirp"
+	chirpKindQuote   = "quote"
+)
+
+func (cfg *apiConfig) handleRechirp(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.getUserFromToken(r, scopeChirpsWrite)
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	if !cfg.requireVerifiedEmail(w, r, userID) {
+		return
+	}
+
+	chirp, ok := cfg.getPathChirp(w, r)
+	if !ok {
+		return
+	}
+
+	original, err := cfg.getSharedChirp(r.Context(), chirp.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, r, "Original chirp not found", http.StatusNotFound)
+			return
+		}
+
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rechirp, err := cfg.dbQueries.CreateChirp(r.Context(), database.CreateChirpParams{
+		UserID:     userID,
+		Kind:       chirpKindRechirp,
+		OriginalID: uuid.NullUUID{UUID: original.ID, Valid: true},
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			utils.RespondWithError(w, r, "Chirp already rechirped", http.StatusConflict)
+			return
+		}
+
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	responses, err := cfg.toChirpResponses(r.Context(), uuid.NullUUID{UUID: userID, Valid: true}, []database.Chirp{rechirp})
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.RespondWithJSON(w, r, responses[0], http.StatusCreated)
+}
+
+func (cfg *apiConfig) handleUndoRechirp(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.getUserFromToken(r, scopeChirpsWrite)
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	chirp, ok := cfg.getPathChirp(w, r)
+	if !ok {
+		return
+	}
+
+	original, err := cfg.getSharedChirp(r.Context(), chirp.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, r, "Original chirp not found", http.StatusNotFound)
+			return
+		}
+
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	deleted, err := cfg.dbQueries.DeleteRechirp(r.Context(), database.DeleteRechirpParams{
+		UserID:     userID,
+		OriginalID: original.ID,
+	})
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		utils.RespondWithError(w, r, "Rechirp not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getSharedChirp returns the chirp that sharing or replying to chirpID acts
+// on: the chirp itself, or the original when chirpID is a plain rechirp.
+func (cfg *apiConfig) getSharedChirp(ctx context.Context, chirpID uuid.UUID) (database.Chirp, error) {
+	chirp, err := cfg.dbQueries.GetChirpById(ctx, chirpID)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	if chirp.Kind != chirpKindRechirp || !chirp.OriginalID.Valid {
+		return chirp, nil
+	}
+
+	return cfg.dbQueries.GetChirpById(ctx, chirp.OriginalID.UUID)
+}
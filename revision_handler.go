@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/aarondever/chirpy/internal/database"
+	"github.com/aarondever/chirpy/internal/utils"
+	"github.com/google/uuid"
+)
+
+type revisionResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (cfg *apiConfig) handleUpdateChirp(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.getUserFromToken(r, scopeChirpsWrite)
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	chirp, ok := cfg.getPathChirp(w, r)
+	if !ok {
+		return
+	}
+	if chirp.UserID != userID {
+		utils.RespondWithError(w, r, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if chirp.Kind == chirpKindRechirp {
+		utils.RespondWithError(w, r, "Rechirps cannot be edited", http.StatusBadRequest)
+		return
+	}
+
+	plan, ok := cfg.getEntitlements(w, r, userID)
+	if !ok {
+		return
+	}
+	if !plan.CanEditChirps {
+		utils.RespondWithError(w, r, "Your plan doesn't allow editing chirps", http.StatusForbidden)
+		return
+	}
+
+	type requestBody struct {
+		Body string `json:"body"`
+	}
+
+	var body requestBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if len(body.Body) > plan.MaxChirpLength {
+		utils.RespondWithError(w, r, "Chirp is too long", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	// keep the body being replaced
+	if err := qtx.CreateChirpRevision(r.Context(), database.CreateChirpRevisionParams{
+		ChirpID: chirp.ID,
+		Body:    chirp.Body,
+	}); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	chirp, err = qtx.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+		ID:   chirp.ID,
+		Body: censorProfane(body.Body),
+	})
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := tagChirp(r.Context(), qtx, chirp); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := mentionUsers(r.Context(), qtx, chirp); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	responses, err := cfg.toChirpResponses(r.Context(), uuid.NullUUID{UUID: userID, Valid: true}, []database.Chirp{chirp})
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.RespondWithJSON(w, r, responses[0], http.StatusOK)
+}
+
+func (cfg *apiConfig) handleGetChirpRevisions(w http.ResponseWriter, r *http.Request) {
+	chirp, ok := cfg.getPathChirp(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := cfg.dbQueries.GetChirpRevisions(r.Context(), chirp.ID)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var response = make([]revisionResponse, 0, len(revisions))
+	for _, revision := range revisions {
+		response = append(response, revisionResponse{
+			ID:        revision.ID,
+			Body:      revision.Body,
+			CreatedAt: revision.CreatedAt,
+		})
+	}
+
+	utils.RespondWithJSON(w, r, response, http.StatusOK)
+}
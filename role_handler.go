@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/aarondever/chirpy/internal/database"
+	"github.com/aarondever/chirpy/internal/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	roleUser      = "user"
+	roleModerator = "moderator"
+	roleAdmin     = "admin"
+)
+
+// roleRank orders the roles; each one can do everything the ones below it can.
+var roleRank = map[string]int{
+	roleUser:      0,
+	roleModerator: 1,
+	roleAdmin:     2,
+}
+
+// requireRole only lets requests through from a logged-in user holding at
+// least role. Personal access tokens are never enough.
+func (cfg *apiConfig) requireRole(role string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := cfg.getUserFromToken(r, "")
+		if err != nil {
+			respondWithTokenError(w, r, err)
+			return
+		}
+
+		ok, err := cfg.hasRole(r.Context(), userID, role)
+		if err != nil {
+			utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			utils.RespondWithError(w, r, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (cfg *apiConfig) hasRole(ctx context.Context, userID uuid.UUID, role string) (bool, error) {
+	user, err := cfg.dbQueries.GetUserByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return roleRank[user.Role] >= roleRank[role], nil
+}
+
+func (cfg *apiConfig) handleSetUserRole(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.getPathUser(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := roleRank[body.Role]; !ok {
+		utils.RespondWithError(w, r, "Role must be user, moderator or admin", http.StatusBadRequest)
+		return
+	}
+
+	user, err := cfg.dbQueries.SetUserRole(r.Context(), database.SetUserRoleParams{
+		ID:   user.ID,
+		Role: body.Role,
+	})
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		ID   uuid.UUID `json:"id"`
+		Role string    `json:"role"`
+	}{
+		ID:   user.ID,
+		Role: user.Role,
+	}
+
+	utils.RespondWithJSON(w, r, response, http.StatusOK)
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/aarondever/chirpy/internal/database"
+	"github.com/aarondever/chirpy/internal/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	scheduledChirpInterval  = 30 * time.Second
+	scheduledChirpBatchSize = 100
+	maxScheduleAhead        = 365 * 24 * time.Hour
+)
+
+type scheduledChirpResponse struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	PublishAt time.Time  `json:"publish_at"`
+	Body      string     `json:"body"`
+	UserID    uuid.UUID  `json:"user_id"`
+	FailedAt  *time.Time `json:"failed_at"`
+	LastError *string    `json:"last_error"`
+}
+
+func toScheduledChirpResponse(chirp database.ScheduledChirp) scheduledChirpResponse {
+	response := scheduledChirpResponse{
+		ID:        chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		PublishAt: chirp.PublishAt,
+		Body:      chirp.Body,
+		UserID:    chirp.UserID,
+	}
+	if chirp.FailedAt.Valid {
+		response.FailedAt = &chirp.FailedAt.Time
+	}
+	if chirp.LastError.Valid {
+		response.LastError = &chirp.LastError.String
+	}
+	return response
+}
+
+func (cfg *apiConfig) handleCreateScheduledChirp(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.getUserFromToken(r, scopeChirpsWrite)
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	if !cfg.requireVerifiedEmail(w, r, userID) {
+		return
+	}
+
+	type requestBody struct {
+		Body      string    `json:"body"`
+		PublishAt time.Time `json:"publish_at"`
+	}
+
+	var body requestBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	plan, ok := cfg.getEntitlements(w, r, userID)
+	if !ok {
+		return
+	}
+	if plan.MaxScheduledChirps == 0 {
+		utils.RespondWithError(w, r, "Your plan doesn't allow scheduling chirps", http.StatusForbidden)
+		return
+	}
+
+	if len(body.Body) > plan.MaxChirpLength {
+		utils.RespondWithError(w, r, "Chirp is too long", http.StatusBadRequest)
+		return
+	}
+
+	// the delay is worked out here and added to NOW() in the database, so the
+	// client's time zone never meets the timestamp column
+	delay := time.Until(body.PublishAt)
+	if delay <= 0 {
+		utils.RespondWithError(w, r, "publish_at must be in the future", http.StatusBadRequest)
+		return
+	}
+	if delay > maxScheduleAhead {
+		utils.RespondWithError(w, r, "publish_at is too far in the future", http.StatusBadRequest)
+		return
+	}
+
+	count, err := cfg.dbQueries.CountScheduledChirps(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if count >= int64(plan.MaxScheduledChirps) {
+		utils.RespondWithError(w, r, "Too many scheduled chirps", http.StatusForbidden)
+		return
+	}
+
+	chirp, err := cfg.dbQueries.CreateScheduledChirp(r.Context(), database.CreateScheduledChirpParams{
+		UserID:       userID,
+		Body:         censorProfane(body.Body),
+		DelaySeconds: int32(delay.Round(time.Second) / time.Second),
+	})
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.RespondWithJSON(w, r, toScheduledChirpResponse(chirp), http.StatusCreated)
+}
+
+func (cfg *apiConfig) handleGetScheduledChirps(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.getUserFromToken(r, scopeChirpsRead)
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	chirps, err := cfg.dbQueries.GetScheduledChirps(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]scheduledChirpResponse, 0, len(chirps))
+	for _, chirp := range chirps {
+		response = append(response, toScheduledChirpResponse(chirp))
+	}
+
+	utils.RespondWithJSON(w, r, response, http.StatusOK)
+}
+
+func (cfg *apiConfig) handleDeleteScheduledChirp(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.getUserFromToken(r, scopeChirpsWrite)
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	scheduledID, err := uuid.Parse(r.PathValue("scheduledID"))
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := cfg.dbQueries.DeleteScheduledChirp(r.Context(), database.DeleteScheduledChirpParams{
+		ID:     scheduledID,
+		UserID: userID,
+	})
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		utils.RespondWithError(w, r, "Scheduled chirp not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// publishScheduledChirps posts up to a batch of chirps whose time has come,
+// each in its own transaction. They were checked against the user's plan when
+// scheduled, so a plan that has lapsed since doesn't hold them back.
+func (cfg *apiConfig) publishScheduledChirps(ctx context.Context) error {
+	for range scheduledChirpBatchSize {
+		claimed, err := cfg.publishNextScheduledChirp(ctx)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// publishNextScheduledChirp reports whether there was a due chirp to publish.
+// One that can't be published is marked failed rather than retried, so it
+// doesn't hold up the ones behind it.
+func (cfg *apiConfig) publishNextScheduledChirp(ctx context.Context) (bool, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	due, err := qtx.ClaimDueScheduledChirps(ctx, 1)
+	if err != nil {
+		return false, err
+	}
+	if len(due) == 0 {
+		return false, nil
+	}
+	scheduled := due[0]
+
+	if err := publishScheduledChirp(ctx, qtx, scheduled); err != nil {
+		tx.Rollback()
+		log.Printf("Failed to publish scheduled chirp %s: %v", scheduled.ID, err)
+
+		return true, cfg.dbQueries.MarkScheduledChirpFailed(ctx, database.MarkScheduledChirpFailedParams{
+			LastError: err.Error(),
+			ID:        scheduled.ID,
+		})
+	}
+
+	return true, tx.Commit()
+}
+
+func publishScheduledChirp(ctx context.Context, q *database.Queries, scheduled database.ScheduledChirp) error {
+	chirp, err := q.CreateChirp(ctx, database.CreateChirpParams{
+		Body:   scheduled.Body,
+		UserID: scheduled.UserID,
+		Kind:   chirpKindChirp,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tagChirp(ctx, q, chirp); err != nil {
+		return err
+	}
+
+	if err := mentionUsers(ctx, q, chirp); err != nil {
+		return err
+	}
+
+	if err := enqueueWebhookEvent(ctx, q, chirp.UserID, eventChirpCreated, newChirpEventData(chirp)); err != nil {
+		return err
+	}
+
+	_, err = q.DeleteScheduledChirp(ctx, database.DeleteScheduledChirpParams{
+		ID:     scheduled.ID,
+		UserID: scheduled.UserID,
+	})
+	return err
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/aarondever/chirpy/internal/database"
+	"github.com/aarondever/chirpy/internal/utils"
+)
+
+// handleSearchChirps ranks chirps against q, which takes web search syntax
+// ("quoted phrases", or, -excluded). phrase additionally requires an exact
+// phrase match.
+func (cfg *apiConfig) handleSearchChirps(w http.ResponseWriter, r *http.Request) {
+	viewer, err := cfg.getViewerFromToken(r)
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	queryParams := r.URL.Query()
+
+	query := strings.TrimSpace(queryParams.Get("q"))
+	phrase := strings.TrimSpace(queryParams.Get("phrase"))
+	if query == "" && phrase == "" {
+		utils.RespondWithError(w, r, "q or phrase is required", http.StatusBadRequest)
+		return
+	}
+
+	page, err := parsePageParams(queryParams)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := cfg.dbQueries.SearchChirps(r.Context(), database.SearchChirpsParams{
+		Query:           query,
+		Phrase:          phrase,
+		AuthorID:        queryParams.Get("author_id"),
+		CursorRank:      page.cursorRank(),
+		CursorCreatedAt: page.cursorCreatedAt(),
+		CursorID:        page.cursorID(),
+		PageSize:        page.fetchSize(),
+	})
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rows, nextCursor := trimPage(page, rows, func(row database.SearchChirpsRow) pageCursor {
+		cursor := chirpCursorOf(row.Chirp)
+		cursor.Rank = row.Rank
+		return cursor
+	})
+
+	chirps := make([]database.Chirp, 0, len(rows))
+	for _, row := range rows {
+		chirps = append(chirps, row.Chirp)
+	}
+
+	cfg.respondWithChirpPage(w, r, viewer, chirps, nextCursor)
+}
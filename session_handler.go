@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/aarondever/chirpy/internal/auth"
+	"github.com/aarondever/chirpy/internal/database"
+	"github.com/aarondever/chirpy/internal/utils"
+	"github.com/google/uuid"
+)
+
+type sessionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+}
+
+func (cfg *apiConfig) handleGetSessions(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.getUserFromToken(r, "")
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	sessions, err := cfg.dbQueries.GetActiveSessions(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]sessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, sessionResponse{
+			ID:         session.ID,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IpAddress,
+		})
+	}
+
+	utils.RespondWithJSON(w, r, response, http.StatusOK)
+}
+
+func (cfg *apiConfig) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.getUserFromToken(r, "")
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	sessionID, err := uuid.Parse(r.PathValue("sessionID"))
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ended, err := cfg.endSession(r.Context(), userID, sessionID)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ended {
+		utils.RespondWithError(w, r, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleDeleteSessions logs the user out everywhere. Access tokens already
+// handed out stay valid until they expire.
+func (cfg *apiConfig) handleDeleteSessions(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.getUserFromToken(r, "")
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	if err := qtx.RevokeUserSessions(r.Context(), userID); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := qtx.RevokeUserRefreshTokens(r.Context(), userID); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// startSession records a new login from r and returns its first refresh token.
+func (cfg *apiConfig) startSession(r *http.Request, userID uuid.UUID) (string, error) {
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	session, err := qtx.CreateSession(r.Context(), database.CreateSessionParams{
+		UserID:    userID,
+		UserAgent: r.UserAgent(),
+		IpAddress: clientIP(r),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	refreshToken := auth.MakeRefreshToken()
+	if _, err := qtx.CreateRfreshToken(r.Context(), database.CreateRfreshTokenParams{
+		TokenHash: auth.HashToken(refreshToken),
+		UserID:    userID,
+		SessionID: session.ID,
+	}); err != nil {
+		return "", err
+	}
+
+	return refreshToken, tx.Commit()
+}
+
+// endSession revokes a session and every refresh token issued for it. It
+// reports false when the user has no such active session.
+func (cfg *apiConfig) endSession(ctx context.Context, userID, sessionID uuid.UUID) (bool, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	revoked, err := qtx.RevokeSession(ctx, database.RevokeSessionParams{
+		ID:     sessionID,
+		UserID: userID,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if err := qtx.RevokeSessionRefreshTokens(ctx, sessionID); err != nil {
+		return false, err
+	}
+
+	return revoked > 0, tx.Commit()
+}
@@ -1,26 +1,90 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, parent_id, root_id, kind, original_id)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
 )
 RETURNING *;
 
--- name: GetChirps :many
-SELECT * FROM chirps 
-WHERE @userID::TEXT = '' OR user_id::TEXT = @userID
-ORDER BY CASE 
-            WHEN @sort::TEXT = 'desc' THEN created_at
-            END DESC,
-        CASE
-            WHEN @sort IS NULL OR @sort::TEXT = 'asc' THEN created_at
-            END ASC;
+-- name: GetChirpsPageAsc :many
+SELECT * FROM chirps
+WHERE (@author_id::TEXT = '' OR user_id::TEXT = @author_id)
+  AND (sqlc.narg(cursor_created_at)::TIMESTAMP IS NULL
+       OR (created_at, id) > (sqlc.narg(cursor_created_at)::TIMESTAMP, sqlc.narg(cursor_id)::UUID))
+ORDER BY created_at ASC, id ASC
+LIMIT @page_size;
+
+-- name: GetChirpsPageDesc :many
+SELECT * FROM chirps
+WHERE (@author_id::TEXT = '' OR user_id::TEXT = @author_id)
+  AND (sqlc.narg(cursor_created_at)::TIMESTAMP IS NULL
+       OR (created_at, id) < (sqlc.narg(cursor_created_at)::TIMESTAMP, sqlc.narg(cursor_id)::UUID))
+ORDER BY created_at DESC, id DESC
+LIMIT @page_size;
+
+-- name: GetChirpsPagePopular :many
+SELECT * FROM chirps
+WHERE (@author_id::TEXT = '' OR user_id::TEXT = @author_id)
+  AND (sqlc.narg(cursor_like_count)::INT IS NULL
+       OR (like_count, created_at, id) < (sqlc.narg(cursor_like_count)::INT, sqlc.narg(cursor_created_at)::TIMESTAMP, sqlc.narg(cursor_id)::UUID))
+ORDER BY like_count DESC, created_at DESC, id DESC
+LIMIT @page_size;
 
 -- name: DeleteChirp :exec
 DELETE FROM chirps WHERE id = $1 AND user_id = $2;
 
 -- name: GetChirpById :one
-SELECT * FROM chirps WHERE id = $1;
\ No newline at end of file
+SELECT * FROM chirps WHERE id = $1;
+
+-- name: GetThread :many
+SELECT * FROM chirps
+WHERE id = $1 OR root_id = $1
+ORDER BY created_at ASC, id ASC;
+
+-- name: ReparentReplies :exec
+UPDATE chirps
+SET parent_id = sqlc.narg(new_parent_id)
+WHERE parent_id = @old_parent_id::UUID;
+
+-- name: PromoteThreadReplies :exec
+WITH RECURSIVE subtree AS (
+    SELECT id, id AS new_root_id FROM chirps
+    WHERE root_id = @old_root_id::UUID AND parent_id IS NULL
+    UNION ALL
+    SELECT c.id, s.new_root_id FROM chirps c
+    JOIN subtree s ON c.parent_id = s.id
+)
+UPDATE chirps
+SET root_id = NULLIF(subtree.new_root_id, chirps.id)
+FROM subtree
+WHERE chirps.id = subtree.id;
+
+-- name: GetChirpsByIDs :many
+SELECT * FROM chirps WHERE id = ANY(@ids::UUID[]);
+
+-- name: DeleteRechirp :execrows
+DELETE FROM chirps
+WHERE user_id = @user_id AND original_id = @original_id::UUID AND kind = 'rechirp';
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2,
+    updated_at = NOW(),
+    edited_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetChirpRate :one
+SELECT COUNT(*)::INT AS chirp_count,
+    COALESCE(CEIL(EXTRACT(EPOCH FROM MIN(created_at) + INTERVAL '1 hour' - NOW())), 0)::INT AS retry_after
+FROM chirps
+WHERE user_id = $1
+  AND kind <> 'rechirp'
+  AND created_at > NOW() - INTERVAL '1 hour';
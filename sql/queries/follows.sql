@@ -0,0 +1,36 @@
+-- name: FollowUser :execrows
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UnfollowUser :exec
+DELETE FROM follows WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: GetFollowers :many
+SELECT follower_id, created_at FROM follows
+WHERE followee_id = @user_id
+  AND (sqlc.narg(cursor_created_at)::TIMESTAMP IS NULL
+       OR (created_at, follower_id) < (sqlc.narg(cursor_created_at)::TIMESTAMP, sqlc.narg(cursor_id)::UUID))
+ORDER BY created_at DESC, follower_id DESC
+LIMIT @page_size;
+
+-- name: GetFollowing :many
+SELECT followee_id, created_at FROM follows
+WHERE follower_id = @user_id
+  AND (sqlc.narg(cursor_created_at)::TIMESTAMP IS NULL
+       OR (created_at, followee_id) < (sqlc.narg(cursor_created_at)::TIMESTAMP, sqlc.narg(cursor_id)::UUID))
+ORDER BY created_at DESC, followee_id DESC
+LIMIT @page_size;
+
+-- name: GetTimeline :many
+SELECT chirps.* FROM chirps
+JOIN follows ON follows.followee_id = chirps.user_id
+WHERE follows.follower_id = @user_id
+  AND (sqlc.narg(cursor_created_at)::TIMESTAMP IS NULL
+       OR (chirps.created_at, chirps.id) < (sqlc.narg(cursor_created_at)::TIMESTAMP, sqlc.narg(cursor_id)::UUID))
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT @page_size;
@@ -0,0 +1,31 @@
+-- name: CreateHashtags :exec
+INSERT INTO hashtags (id, tag, created_at)
+SELECT gen_random_uuid(), tag, NOW() FROM unnest(@tags::TEXT[]) AS tag
+ON CONFLICT (tag) DO NOTHING;
+
+-- name: TagChirp :exec
+INSERT INTO chirp_hashtags (chirp_id, hashtag_id)
+SELECT @chirp_id::UUID, id FROM hashtags WHERE tag = ANY(@tags::TEXT[])
+ON CONFLICT DO NOTHING;
+
+-- name: UntagChirp :exec
+DELETE FROM chirp_hashtags WHERE chirp_id = $1;
+
+-- name: GetHashtagChirps :many
+SELECT chirps.* FROM chirps
+JOIN chirp_hashtags ON chirp_hashtags.chirp_id = chirps.id
+JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+WHERE hashtags.tag = @tag
+  AND (sqlc.narg(cursor_created_at)::TIMESTAMP IS NULL
+       OR (chirps.created_at, chirps.id) < (sqlc.narg(cursor_created_at)::TIMESTAMP, sqlc.narg(cursor_id)::UUID))
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT @page_size;
+
+-- name: GetTrendingHashtags :many
+SELECT hashtags.tag, COUNT(*) AS chirp_count FROM hashtags
+JOIN chirp_hashtags ON chirp_hashtags.hashtag_id = hashtags.id
+JOIN chirps ON chirps.id = chirp_hashtags.chirp_id
+WHERE chirps.created_at > NOW() - make_interval(hours => @hours::INT)
+GROUP BY hashtags.tag
+ORDER BY chirp_count DESC, hashtags.tag ASC
+LIMIT @tag_limit;
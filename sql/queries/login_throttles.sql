@@ -0,0 +1,24 @@
+-- name: RecordLoginFailure :one
+INSERT INTO login_throttles (scope, key, failures, last_failure_at)
+VALUES ($1, $2, 1, NOW())
+ON CONFLICT (scope, key) DO UPDATE
+SET failures = CASE
+        WHEN login_throttles.last_failure_at < NOW() - INTERVAL '1 hour' THEN 1
+        ELSE login_throttles.failures + 1
+    END,
+    last_failure_at = NOW()
+RETURNING failures;
+
+-- name: LockLogin :exec
+UPDATE login_throttles
+SET locked_until = NOW() + @lock_seconds::INT * INTERVAL '1 second'
+WHERE scope = @scope AND key = @key;
+
+-- name: GetLoginLockout :one
+SELECT COALESCE(MAX(CEIL(EXTRACT(EPOCH FROM locked_until - NOW()))), 0)::INT AS retry_after
+FROM login_throttles
+WHERE locked_until > NOW()
+  AND ((scope = 'account' AND key = @account::TEXT) OR (scope = 'ip' AND key = @ip::TEXT));
+
+-- name: ClearLoginFailures :exec
+DELETE FROM login_throttles WHERE scope = $1 AND key = $2;
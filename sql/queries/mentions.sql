@@ -0,0 +1,25 @@
+-- name: CreateMention :exec
+INSERT INTO mentions (chirp_id, user_id, start_offset, end_offset)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteChirpMentions :exec
+DELETE FROM mentions WHERE chirp_id = $1;
+
+-- name: GetChirpsMentions :many
+SELECT * FROM mentions
+WHERE chirp_id = ANY(@chirp_ids::UUID[])
+ORDER BY chirp_id, start_offset;
+
+-- name: GetMentionChirps :many
+SELECT * FROM chirps
+WHERE chirps.id IN (SELECT mentions.chirp_id FROM mentions WHERE mentions.user_id = @user_id)
+  AND (sqlc.narg(cursor_created_at)::TIMESTAMP IS NULL
+       OR (created_at, id) < (sqlc.narg(cursor_created_at)::TIMESTAMP, sqlc.narg(cursor_id)::UUID))
+ORDER BY created_at DESC, id DESC
+LIMIT @page_size;
@@ -1,15 +1,29 @@
 -- name: CreateRfreshToken :one
-INSERT INTO refresh_tokens (token, created_at, updated_at, expires_at, user_id)
+INSERT INTO refresh_tokens (token_hash, created_at, updated_at, expires_at, user_id, session_id)
 VALUES (
     $1,
     NOW(),
     NOW(),
     NOW() + INTERVAL '60 days',
-    $2
+    $2,
+    $3
 )
 RETURNING *;
 
--- name: RevokeRefreshToken :exec
+-- name: GetRefreshToken :one
+SELECT * FROM refresh_tokens WHERE token_hash = $1;
+
+-- name: UseRefreshToken :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > NOW();
+
+-- name: RevokeSessionRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE session_id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeUserRefreshTokens :exec
 UPDATE refresh_tokens
 SET revoked_at = NOW(), updated_at = NOW()
-WHERE token = $1;
\ No newline at end of file
+WHERE user_id = $1 AND revoked_at IS NULL;
@@ -0,0 +1,34 @@
+-- name: CreateScheduledChirp :one
+INSERT INTO scheduled_chirps (id, created_at, user_id, body, publish_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    @user_id,
+    @body,
+    NOW() + @delay_seconds::INT * INTERVAL '1 second'
+)
+RETURNING *;
+
+-- name: CountScheduledChirps :one
+SELECT COUNT(*) FROM scheduled_chirps WHERE user_id = $1 AND failed_at IS NULL;
+
+-- name: GetScheduledChirps :many
+SELECT * FROM scheduled_chirps
+WHERE user_id = $1
+ORDER BY publish_at ASC, id ASC;
+
+-- name: DeleteScheduledChirp :execrows
+DELETE FROM scheduled_chirps WHERE id = $1 AND user_id = $2;
+
+-- name: ClaimDueScheduledChirps :many
+SELECT * FROM scheduled_chirps
+WHERE publish_at <= NOW() AND failed_at IS NULL
+ORDER BY publish_at ASC, id ASC
+LIMIT @batch_size
+FOR UPDATE SKIP LOCKED;
+
+-- name: MarkScheduledChirpFailed :exec
+UPDATE scheduled_chirps
+SET failed_at = NOW(),
+    last_error = @last_error::TEXT
+WHERE id = @id;
@@ -0,0 +1,13 @@
+-- name: SearchChirps :many
+WITH search AS (
+    SELECT websearch_to_tsquery('english', @query::TEXT) && phraseto_tsquery('english', @phrase::TEXT) AS query
+)
+SELECT sqlc.embed(chirps), ts_rank(chirps.search_vector, search.query)::REAL AS rank
+FROM chirps, search
+WHERE chirps.search_vector @@ search.query
+  AND (@author_id::TEXT = '' OR chirps.user_id::TEXT = @author_id)
+  AND (sqlc.narg(cursor_rank)::REAL IS NULL
+       OR (ts_rank(chirps.search_vector, search.query)::REAL, chirps.created_at, chirps.id)
+          < (sqlc.narg(cursor_rank)::REAL, sqlc.narg(cursor_created_at)::TIMESTAMP, sqlc.narg(cursor_id)::UUID))
+ORDER BY rank DESC, chirps.created_at DESC, chirps.id DESC
+LIMIT @page_size;
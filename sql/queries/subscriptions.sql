@@ -0,0 +1,54 @@
+-- name: GetSubscription :one
+SELECT * FROM subscriptions WHERE user_id = $1;
+
+-- name: HasActiveSubscription :one
+SELECT EXISTS (
+    SELECT 1 FROM subscriptions
+    WHERE user_id = $1
+        AND status <> 'expired'
+        AND current_period_end > NOW()
+);
+
+-- name: StartSubscription :one
+INSERT INTO subscriptions (user_id, created_at, updated_at, status, current_period_end)
+VALUES ($1, NOW(), NOW(), 'active', NOW() + INTERVAL '1 month')
+ON CONFLICT (user_id) DO UPDATE
+SET updated_at = NOW(),
+    status = 'active',
+    current_period_end = GREATEST(subscriptions.current_period_end, NOW() + INTERVAL '1 month')
+RETURNING *;
+
+-- name: RenewSubscription :one
+INSERT INTO subscriptions (user_id, created_at, updated_at, status, current_period_end)
+VALUES ($1, NOW(), NOW(), 'active', NOW() + INTERVAL '1 month')
+ON CONFLICT (user_id) DO UPDATE
+SET updated_at = NOW(),
+    status = 'active',
+    current_period_end = GREATEST(subscriptions.current_period_end, NOW()) + INTERVAL '1 month'
+RETURNING *;
+
+-- name: CancelSubscription :execrows
+UPDATE subscriptions
+SET updated_at = NOW(),
+    status = 'canceled'
+WHERE user_id = $1 AND status IN ('active', 'past_due');
+
+-- name: MarkSubscriptionPastDue :execrows
+UPDATE subscriptions
+SET updated_at = NOW(),
+    status = 'past_due',
+    current_period_end = GREATEST(current_period_end, NOW() + INTERVAL '3 days')
+WHERE user_id = $1 AND status = 'active';
+
+-- name: EndSubscription :execrows
+UPDATE subscriptions
+SET updated_at = NOW(),
+    status = 'expired',
+    current_period_end = LEAST(current_period_end, NOW())
+WHERE user_id = $1 AND status <> 'expired';
+
+-- name: ExpireSubscriptions :execrows
+UPDATE subscriptions
+SET updated_at = NOW(),
+    status = 'expired'
+WHERE status <> 'expired' AND current_period_end <= NOW();
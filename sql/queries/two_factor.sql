@@ -0,0 +1,60 @@
+-- name: UpsertPendingTOTP :execrows
+INSERT INTO user_totp (user_id, secret, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret,
+    created_at = EXCLUDED.created_at,
+    last_used_step = 0
+WHERE user_totp.enabled_at IS NULL;
+
+-- name: GetUserTOTP :one
+SELECT * FROM user_totp WHERE user_id = $1;
+
+-- name: EnableTOTP :execrows
+UPDATE user_totp
+SET enabled_at = NOW(),
+    last_used_step = $2
+WHERE user_id = $1 AND enabled_at IS NULL;
+
+-- name: UseTOTPStep :execrows
+UPDATE user_totp
+SET last_used_step = $2
+WHERE user_id = $1 AND last_used_step < $2;
+
+-- name: DeleteUserTOTP :exec
+DELETE FROM user_totp WHERE user_id = $1;
+
+-- name: CreateRecoveryCodes :exec
+INSERT INTO recovery_codes (user_id, code_hash, created_at)
+SELECT @user_id::UUID, UNNEST(@code_hashes::TEXT[]), NOW();
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes WHERE user_id = $1;
+
+-- name: CreateLoginChallenge :exec
+INSERT INTO login_challenges (token_hash, user_id, created_at, expires_at)
+VALUES (
+    $1,
+    $2,
+    NOW(),
+    NOW() + INTERVAL '5 minutes'
+);
+
+-- name: GetLoginChallenge :one
+SELECT * FROM login_challenges
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW() AND attempts < 5;
+
+-- name: FailLoginChallenge :exec
+UPDATE login_challenges
+SET attempts = attempts + 1
+WHERE token_hash = $1;
+
+-- name: UseLoginChallenge :execrows
+UPDATE login_challenges
+SET used_at = NOW()
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW() AND attempts < 5;
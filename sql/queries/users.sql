@@ -1,11 +1,14 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle, display_name, bio)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5
 )
 RETURNING *;
 
@@ -16,20 +19,52 @@ SELECT * FROM users WHERE email = $1;
 UPDATE users
 SET updated_at = NOW(),
     email = $2,
-    hashed_password = $3
+    email_verified_at = CASE WHEN email = $2 THEN email_verified_at END,
+    hashed_password = $3,
+    handle = COALESCE(sqlc.narg(handle), handle),
+    display_name = COALESCE(sqlc.narg(display_name), display_name),
+    bio = COALESCE(sqlc.narg(bio), bio)
 WHERE id = $1
 RETURNING *;
 
 -- name: ResetUsers :exec
 DELETE FROM users;
 
--- name: GetUserFromRefreshToken :one
-SELECT user_id FROM refresh_tokens
-WHERE token = $1 AND revoked_at IS NULL;
+-- name: GetUserByID :one
+SELECT * FROM users WHERE id = $1;
 
--- name: UpgradeUser :one
+
+-- name: GetUsersByHandles :many
+SELECT id, handle FROM users
+WHERE LOWER(handle) = ANY(@handles::TEXT[]);
+
+-- name: GetUsersByIDs :many
+SELECT id, handle, display_name FROM users
+WHERE id = ANY(@ids::UUID[]);
+
+-- name: GetUserProfile :one
+SELECT users.id, users.created_at, users.handle, users.display_name, users.bio,
+    (SELECT COUNT(*) FROM chirps WHERE chirps.user_id = users.id) AS chirp_count,
+    (SELECT COUNT(*) FROM follows WHERE follows.followee_id = users.id) AS follower_count,
+    (SELECT COUNT(*) FROM follows WHERE follows.follower_id = users.id) AS following_count
+FROM users
+WHERE LOWER(users.handle) = LOWER(@handle);
+
+-- name: UpdateUserPassword :exec
 UPDATE users
 SET updated_at = NOW(),
-    is_chirpy_red = TRUE
+    hashed_password = $2
+WHERE id = $1;
+
+-- name: VerifyUserEmail :execrows
+UPDATE users
+SET updated_at = NOW(),
+    email_verified_at = COALESCE(email_verified_at, NOW())
+WHERE id = $1 AND email = $2;
+
+-- name: SetUserRole :one
+UPDATE users
+SET updated_at = NOW(),
+    role = $2
 WHERE id = $1
-RETURNING *;
\ No newline at end of file
+RETURNING *;
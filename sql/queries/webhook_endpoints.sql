@@ -0,0 +1,87 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, created_at, user_id, url, events, secret)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: CountWebhookEndpoints :one
+SELECT COUNT(*) FROM webhook_endpoints WHERE user_id = $1;
+
+-- name: GetWebhookEndpoints :many
+SELECT * FROM webhook_endpoints
+WHERE user_id = $1
+ORDER BY created_at DESC, id DESC;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints WHERE id = $1 AND user_id = $2;
+
+-- name: EnqueueWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (id, created_at, endpoint_id, event_type, payload, status, attempts, next_attempt_at)
+SELECT gen_random_uuid(), NOW(), id, @event_type::TEXT, @payload::JSONB, 'pending', 0, NOW()
+FROM webhook_endpoints
+WHERE user_id = @user_id AND @event_type::TEXT = ANY(events);
+
+-- name: ClaimWebhookDeliveries :many
+WITH claimed AS (
+    UPDATE webhook_deliveries
+    SET next_attempt_at = NOW() + @lease_seconds::INT * INTERVAL '1 second'
+    WHERE webhook_deliveries.id IN (
+        SELECT id FROM webhook_deliveries
+        WHERE status = 'pending' AND next_attempt_at <= NOW()
+        ORDER BY next_attempt_at ASC
+        LIMIT @batch_size
+        FOR UPDATE SKIP LOCKED
+    )
+    RETURNING webhook_deliveries.id, webhook_deliveries.endpoint_id, webhook_deliveries.event_type,
+        webhook_deliveries.payload, webhook_deliveries.attempts
+)
+SELECT claimed.id, claimed.event_type, claimed.payload, claimed.attempts, webhook_endpoints.url, webhook_endpoints.secret
+FROM claimed
+JOIN webhook_endpoints ON webhook_endpoints.id = claimed.endpoint_id;
+
+-- name: RecordWebhookDeliveryAttempt :exec
+INSERT INTO webhook_delivery_attempts (id, delivery_id, attempted_at, status_code, error, duration_ms)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4
+);
+
+-- name: MarkWebhookDeliverySucceeded :exec
+UPDATE webhook_deliveries
+SET status = 'succeeded',
+    attempts = attempts + 1,
+    last_error = NULL,
+    delivered_at = NOW()
+WHERE id = $1;
+
+-- name: MarkWebhookDeliveryFailed :exec
+UPDATE webhook_deliveries
+SET attempts = attempts + 1,
+    last_error = @last_error::TEXT,
+    status = CASE WHEN @give_up::BOOLEAN THEN 'failed' ELSE 'pending' END,
+    next_attempt_at = NOW() + @retry_seconds::INT * INTERVAL '1 second'
+WHERE id = @id;
+
+-- name: GetWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = $1
+ORDER BY created_at DESC, id DESC
+LIMIT @page_size;
+
+-- name: GetWebhookDeliveryAttempts :many
+SELECT * FROM webhook_delivery_attempts
+WHERE delivery_id = ANY(@delivery_ids::UUID[])
+ORDER BY attempted_at ASC, id ASC;
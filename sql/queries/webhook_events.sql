@@ -0,0 +1,34 @@
+-- name: RecordWebhookEvent :one
+INSERT INTO webhook_events (id, source, event_type, payload, status, attempts, received_at)
+VALUES ($1, $2, $3, $4, 'pending', 1, NOW())
+ON CONFLICT (id) DO UPDATE
+SET attempts = webhook_events.attempts + 1
+RETURNING *;
+
+-- name: ClaimWebhookEvent :one
+UPDATE webhook_events
+SET status = 'processing'
+WHERE id = $1 AND status IN ('pending', 'failed')
+RETURNING *;
+
+-- name: SetWebhookEventStatus :exec
+UPDATE webhook_events
+SET status = @status::TEXT,
+    last_error = sqlc.narg(last_error),
+    processed_at = CASE WHEN @status::TEXT = 'processed' THEN NOW() ELSE processed_at END
+WHERE id = @id;
+
+-- name: RetryWebhookEvent :execrows
+UPDATE webhook_events
+SET status = 'processing',
+    attempts = attempts + 1
+WHERE id = $1 AND status = 'failed';
+
+-- name: GetWebhookEvent :one
+SELECT * FROM webhook_events WHERE id = $1;
+
+-- name: ListWebhookEvents :many
+SELECT * FROM webhook_events
+WHERE sqlc.narg(status)::TEXT IS NULL OR status = sqlc.narg(status)::TEXT
+ORDER BY received_at DESC, id DESC
+LIMIT @page_size;
@@ -0,0 +1,27 @@
+-- +goose Up
+ALTER TABLE chirps DROP CONSTRAINT chirps_body_key;
+ALTER TABLE chirps ADD COLUMN kind TEXT NOT NULL DEFAULT 'chirp';
+ALTER TABLE chirps ADD COLUMN original_id UUID REFERENCES chirps(id) ON DELETE SET NULL;
+CREATE INDEX chirps_original_id_idx ON chirps (original_id);
+CREATE UNIQUE INDEX chirps_user_id_rechirp_idx ON chirps (user_id, original_id) WHERE kind = 'rechirp';
+
+-- quotes outlive their original, plain rechirps go with it
+-- +goose StatementBegin
+CREATE FUNCTION delete_chirp_rechirps() RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM chirps WHERE original_id = OLD.id AND kind = 'rechirp';
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirps_delete_rechirps
+BEFORE DELETE ON chirps
+FOR EACH ROW EXECUTE FUNCTION delete_chirp_rechirps();
+
+-- +goose Down
+DROP TRIGGER chirps_delete_rechirps ON chirps;
+DROP FUNCTION delete_chirp_rechirps;
+ALTER TABLE chirps DROP COLUMN original_id;
+ALTER TABLE chirps DROP COLUMN kind;
+ALTER TABLE chirps ADD CONSTRAINT chirps_body_key UNIQUE (body);
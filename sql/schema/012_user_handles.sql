@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN handle TEXT;
+CREATE UNIQUE INDEX users_handle_idx ON users (LOWER(handle));
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN handle;
@@ -0,0 +1,36 @@
+-- +goose Up
+CREATE TABLE sessions (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    last_used_at TIMESTAMP NOT NULL,
+    user_agent TEXT NOT NULL,
+    ip_address TEXT NOT NULL,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+
+-- every existing token family becomes a session
+INSERT INTO sessions (id, user_id, created_at, last_used_at, user_agent, ip_address, revoked_at)
+SELECT family_id, user_id, MIN(created_at), MAX(updated_at), '', '',
+    CASE WHEN BOOL_AND(revoked_at IS NOT NULL) THEN MAX(revoked_at) END
+FROM refresh_tokens
+GROUP BY family_id, user_id;
+
+ALTER TABLE refresh_tokens RENAME COLUMN family_id TO session_id;
+ALTER INDEX refresh_tokens_family_id_idx RENAME TO refresh_tokens_session_id_idx;
+ALTER TABLE refresh_tokens ADD CONSTRAINT refresh_tokens_session_id_fkey
+    FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE;
+
+ALTER TABLE refresh_tokens RENAME COLUMN token TO token_hash;
+UPDATE refresh_tokens SET token_hash = ENCODE(SHA256(token_hash::BYTEA), 'hex');
+
+-- +goose Down
+-- hashes can't be turned back into tokens, so everyone has to log in again
+DELETE FROM refresh_tokens;
+ALTER TABLE refresh_tokens RENAME COLUMN token_hash TO token;
+ALTER TABLE refresh_tokens DROP CONSTRAINT refresh_tokens_session_id_fkey;
+ALTER INDEX refresh_tokens_session_id_idx RENAME TO refresh_tokens_family_id_idx;
+ALTER TABLE refresh_tokens RENAME COLUMN session_id TO family_id;
+DROP TABLE sessions;
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE webhook_events (
+    id TEXT PRIMARY KEY,
+    source TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL,
+    attempts INTEGER NOT NULL,
+    last_error TEXT,
+    received_at TIMESTAMP NOT NULL,
+    processed_at TIMESTAMP
+);
+
+CREATE INDEX webhook_events_status_received_at_idx ON webhook_events (status, received_at DESC);
+
+-- +goose Down
+DROP TABLE webhook_events;
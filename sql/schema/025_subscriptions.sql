@@ -0,0 +1,30 @@
+-- +goose Up
+CREATE TABLE subscriptions (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('active', 'canceled', 'past_due', 'expired')),
+    current_period_end TIMESTAMP NOT NULL
+);
+
+CREATE INDEX subscriptions_status_current_period_end_idx ON subscriptions (status, current_period_end);
+
+-- existing members get a full period from now, since we never stored when theirs ends
+INSERT INTO subscriptions (user_id, created_at, updated_at, status, current_period_end)
+SELECT id, NOW(), NOW(), 'active', NOW() + INTERVAL '1 month'
+FROM users
+WHERE is_chirpy_red;
+
+ALTER TABLE users DROP COLUMN is_chirpy_red;
+
+-- +goose Down
+ALTER TABLE users ADD COLUMN is_chirpy_red BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE users
+SET is_chirpy_red = TRUE
+FROM subscriptions
+WHERE subscriptions.user_id = users.id
+    AND subscriptions.status <> 'expired'
+    AND subscriptions.current_period_end > NOW();
+
+DROP TABLE subscriptions;
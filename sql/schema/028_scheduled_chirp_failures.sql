@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE scheduled_chirps ADD COLUMN failed_at TIMESTAMP;
+ALTER TABLE scheduled_chirps ADD COLUMN last_error TEXT;
+
+-- +goose Down
+ALTER TABLE scheduled_chirps DROP COLUMN last_error;
+ALTER TABLE scheduled_chirps DROP COLUMN failed_at;
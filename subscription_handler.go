@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Polka's subscription events. A renewal or upgrade starts a new period; a
+// cancellation keeps benefits until the paid period ends; a failed payment
+// leaves a short grace period; a downgrade ends benefits right away.
+const (
+	polkaEventUpgraded      = "user.upgraded"
+	polkaEventRenewed       = "user.renewed"
+	polkaEventCanceled      = "user.canceled"
+	polkaEventPaymentFailed = "user.payment_failed"
+	polkaEventDowngraded    = "user.downgraded"
+)
+
+const subscriptionExpiryInterval = time.Minute
+
+// applySubscriptionEvent updates the user's subscription for a Polka event.
+// Events for users without a subscription that has anything left to change
+// are ignored rather than failed.
+func (cfg *apiConfig) applySubscriptionEvent(ctx context.Context, event string, userID uuid.UUID) (string, error) {
+	var err error
+	var changed int64 = 1
+
+	switch event {
+	case polkaEventUpgraded:
+		_, err = cfg.dbQueries.StartSubscription(ctx, userID)
+	case polkaEventRenewed:
+		_, err = cfg.dbQueries.RenewSubscription(ctx, userID)
+	case polkaEventCanceled:
+		changed, err = cfg.dbQueries.CancelSubscription(ctx, userID)
+	case polkaEventPaymentFailed:
+		changed, err = cfg.dbQueries.MarkSubscriptionPastDue(ctx, userID)
+	case polkaEventDowngraded:
+		changed, err = cfg.dbQueries.EndSubscription(ctx, userID)
+	default:
+		return webhookStatusIgnored, nil
+	}
+
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return "", fmt.Errorf("%w: %s", errWebhookUserNotFound, userID)
+		}
+		return "", err
+	}
+
+	if changed == 0 {
+		return webhookStatusIgnored, nil
+	}
+
+	return webhookStatusProcessed, nil
+}
+
+// isChirpyRed reports whether the user has a subscription whose period hasn't
+// ended, so benefits lapse on time even before the expiry job marks it.
+func (cfg *apiConfig) isChirpyRed(ctx context.Context, userID uuid.UUID) (bool, error) {
+	return cfg.dbQueries.HasActiveSubscription(ctx, userID)
+}
+
+// expireSubscriptions marks subscriptions past their period end as expired.
+func (cfg *apiConfig) expireSubscriptions(ctx context.Context) error {
+	expired, err := cfg.dbQueries.ExpireSubscriptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	if expired > 0 {
+		log.Printf("Expired %d subscriptions", expired)
+	}
+	return nil
+}
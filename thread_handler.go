@@ -0,0 +1,91 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/aarondever/chirpy/internal/utils"
+	"github.com/google/uuid"
+)
+
+type threadResponse struct {
+	chirpResponse
+	Replies []*threadResponse `json:"replies"`
+}
+
+func (cfg *apiConfig) handleGetThread(w http.ResponseWriter, r *http.Request) {
+	viewer, err := cfg.getViewerFromToken(r)
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	v := r.PathValue("chirpID")
+	chirpID, err := uuid.Parse(v)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	chirp, err := cfg.dbQueries.GetChirpById(r.Context(), chirpID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, r, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rootID := chirp.ID
+	if chirp.RootID.Valid {
+		rootID = chirp.RootID.UUID
+	}
+
+	chirps, err := cfg.dbQueries.GetThread(r.Context(), rootID)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	responses, err := cfg.toChirpResponses(r.Context(), viewer, chirps)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// chirps come back oldest first, so every parent is seen before its replies
+	nodes := make(map[uuid.UUID]*threadResponse, len(chirps))
+	var root *threadResponse
+	for i, chirp := range chirps {
+		node := &threadResponse{
+			chirpResponse: responses[i],
+			Replies:       []*threadResponse{},
+		}
+		nodes[chirp.ID] = node
+
+		if chirp.ID == rootID {
+			root = node
+			continue
+		}
+
+		// replies whose parent is gone hang off the root
+		parent, ok := nodes[chirp.ParentID.UUID]
+		if !ok {
+			parent = root
+		}
+		if parent != nil {
+			parent.Replies = append(parent.Replies, node)
+		}
+	}
+
+	// the root was deleted between the two queries
+	if root == nil {
+		utils.RespondWithError(w, r, "Chirp not found", http.StatusNotFound)
+		return
+	}
+
+	utils.RespondWithJSON(w, r, root, http.StatusOK)
+}
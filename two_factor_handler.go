@@ -0,0 +1,370 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/aarondever/chirpy/internal/auth"
+	"github.com/aarondever/chirpy/internal/database"
+	"github.com/aarondever/chirpy/internal/utils"
+	"github.com/google/uuid"
+)
+
+const recoveryCodeCount = 10
+
+var totpCodePattern = regexp.MustCompile(`^[0-9]{6}$`)
+
+type twoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type loginChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+}
+
+func (cfg *apiConfig) handleEnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.getUserFromToken(r, "")
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// enrolling again before confirming just replaces the pending secret
+	secret := auth.GenerateTOTPSecret()
+	enrolled, err := cfg.dbQueries.UpsertPendingTOTP(r.Context(), database.UpsertPendingTOTPParams{
+		UserID: userID,
+		Secret: secret,
+	})
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if enrolled == 0 {
+		utils.RespondWithError(w, r, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	account := user.Email
+	if user.Handle.Valid {
+		account = user.Handle.String
+	}
+
+	response := struct {
+		Secret     string `json:"secret"`
+		OTPAuthURI string `json:"otpauth_uri"`
+	}{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI(secret, "Chirpy", account),
+	}
+
+	utils.RespondWithJSON(w, r, response, http.StatusOK)
+}
+
+// handleConfirmTwoFactor turns 2FA on once the user proves their app works,
+// and hands out the recovery codes. This is the only time they are shown.
+func (cfg *apiConfig) handleConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.getUserFromToken(r, "")
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	var body twoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	totp, err := cfg.dbQueries.GetUserTOTP(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, r, "Two-factor enrollment has not been started", http.StatusBadRequest)
+			return
+		}
+
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if totp.EnabledAt.Valid {
+		utils.RespondWithError(w, r, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	step, ok := auth.ValidateTOTP(totp.Secret, body.Code, time.Now())
+	if !ok {
+		utils.RespondWithError(w, r, "Invalid code", http.StatusBadRequest)
+		return
+	}
+
+	codes := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, auth.HashToken(auth.NormalizeRecoveryCode(code)))
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	enabled, err := qtx.EnableTOTP(r.Context(), database.EnableTOTPParams{
+		UserID:       userID,
+		LastUsedStep: step,
+	})
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if enabled == 0 {
+		utils.RespondWithError(w, r, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	if err := qtx.DeleteRecoveryCodes(r.Context(), userID); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := qtx.CreateRecoveryCodes(r.Context(), database.CreateRecoveryCodesParams{
+		UserID:     userID,
+		CodeHashes: hashes,
+	}); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{
+		RecoveryCodes: codes,
+	}
+
+	utils.RespondWithJSON(w, r, response, http.StatusOK)
+}
+
+func (cfg *apiConfig) handleDisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID, err := cfg.getUserFromToken(r, "")
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	var body twoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ok, err := checkSecondFactor(r.Context(), cfg.dbQueries, userID, body.Code)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		utils.RespondWithError(w, r, "Invalid code", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	if err := qtx.DeleteUserTOTP(r.Context(), userID); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := qtx.DeleteRecoveryCodes(r.Context(), userID); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleLoginTwoFactor is the second step of logging in: it trades the
+// challenge token from handleLogin and a TOTP or recovery code for the usual
+// access and refresh tokens.
+func (cfg *apiConfig) handleLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	challengeHash := auth.HashToken(body.ChallengeToken)
+
+	challenge, err := cfg.dbQueries.GetLoginChallenge(r.Context(), challengeHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, r, "Invalid or expired challenge token", http.StatusUnauthorized)
+			return
+		}
+
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(r.Context(), challenge.UserID)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !cfg.checkLoginThrottle(w, r, user.Email) {
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	// the challenge is claimed before the code is checked, and both commit
+	// together, so a recovery code is never spent on a challenge that fails
+	used, err := qtx.UseLoginChallenge(r.Context(), challengeHash)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if used == 0 {
+		utils.RespondWithError(w, r, "Invalid or expired challenge token", http.StatusUnauthorized)
+		return
+	}
+
+	ok, err := checkSecondFactor(r.Context(), qtx, user.ID, body.Code)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		// release the challenge before counting the failure against it
+		tx.Rollback()
+
+		// a challenge only survives a few wrong guesses, and they count
+		// against the account like wrong passwords do
+		if err := cfg.dbQueries.FailLoginChallenge(r.Context(), challengeHash); err != nil {
+			utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := cfg.recordLoginFailure(r, user.Email); err != nil {
+			utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		utils.RespondWithError(w, r, "Invalid code", http.StatusUnauthorized)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	cfg.respondWithLogin(w, r, user)
+}
+
+func (cfg *apiConfig) respondWithLoginChallenge(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	token := auth.MakeRandomToken()
+	if err := cfg.dbQueries.CreateLoginChallenge(r.Context(), database.CreateLoginChallengeParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    userID,
+	}); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := loginChallengeResponse{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+	}
+
+	utils.RespondWithJSON(w, r, response, http.StatusOK)
+}
+
+func (cfg *apiConfig) twoFactorEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	totp, err := cfg.dbQueries.GetUserTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return totp.EnabledAt.Valid, nil
+}
+
+// checkSecondFactor accepts either a current TOTP code or an unused recovery
+// code. Both are consumed, so neither works a second time.
+func checkSecondFactor(ctx context.Context, q *database.Queries, userID uuid.UUID, code string) (bool, error) {
+	totp, err := q.GetUserTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if !totp.EnabledAt.Valid {
+		return false, nil
+	}
+
+	if totpCodePattern.MatchString(code) {
+		step, ok := auth.ValidateTOTP(totp.Secret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+
+		used, err := q.UseTOTPStep(ctx, database.UseTOTPStepParams{
+			UserID:       userID,
+			LastUsedStep: step,
+		})
+		return used > 0, err
+	}
+
+	used, err := q.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+		UserID:   userID,
+		CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(code)),
+	})
+	return used > 0, err
+}
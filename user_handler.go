@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"log"
 	"net/http"
 	"time"
 
@@ -14,26 +16,181 @@ import (
 )
 
 type userResponse struct {
-	ID           uuid.UUID `json:"id"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	Email        string    `json:"email"`
-	Token        string    `json:"token"`
-	RefreshToken string    `json:"refresh_token"`
-	IsChirpyRed  bool      `json:"is_chirpy_red"`
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	Token         string    `json:"token"`
+	RefreshToken  string    `json:"refresh_token"`
+	IsChirpyRed   bool      `json:"is_chirpy_red"`
+	Role          string    `json:"role"`
+	Handle        string    `json:"handle"`
+	DisplayName   string    `json:"display_name"`
+	Bio           string    `json:"bio"`
 }
 
 type chirpResponse struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID        uuid.UUID         `json:"id"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	Body      string            `json:"body"`
+	UserID    uuid.UUID         `json:"user_id"`
+	InReplyTo uuid.NullUUID     `json:"in_reply_to"`
+	RootID    uuid.NullUUID     `json:"root_id"`
+	LikeCount int32             `json:"like_count"`
+	LikedByMe bool              `json:"liked_by_me"`
+	Kind      string            `json:"kind"`
+	Original  *chirpResponse    `json:"original"`
+	Edited    bool              `json:"edited"`
+	Mentions  []mentionResponse `json:"mentions"`
+	Author    *authorResponse   `json:"author"`
+}
+
+func toChirpResponse(chirp database.Chirp) chirpResponse {
+	return chirpResponse{
+		ID:        chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		Body:      chirp.Body,
+		UserID:    chirp.UserID,
+		InReplyTo: chirp.ParentID,
+		RootID:    chirp.RootID,
+		LikeCount: chirp.LikeCount,
+		Kind:      chirp.Kind,
+		Edited:    chirp.EditedAt.Valid,
+		Mentions:  []mentionResponse{},
+	}
+}
+
+// toChirpResponses converts a batch of chirps, filling in the fields that
+// depend on other rows or on who is looking at them. viewer is unset for
+// anonymous readers.
+func (cfg *apiConfig) toChirpResponses(ctx context.Context, viewer uuid.NullUUID, chirps []database.Chirp) ([]chirpResponse, error) {
+	responses := make([]chirpResponse, 0, len(chirps))
+	var originalIDs []uuid.UUID
+	for _, chirp := range chirps {
+		responses = append(responses, toChirpResponse(chirp))
+		if chirp.OriginalID.Valid {
+			originalIDs = append(originalIDs, chirp.OriginalID.UUID)
+		}
+	}
+
+	// rechirps and quotes embed the chirp they share, one level deep
+	originals := make(map[uuid.UUID]*chirpResponse, len(originalIDs))
+	if len(originalIDs) > 0 {
+		chirps, err := cfg.dbQueries.GetChirpsByIDs(ctx, originalIDs)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, chirp := range chirps {
+			original := toChirpResponse(chirp)
+			originals[chirp.ID] = &original
+		}
+	}
+
+	for i, chirp := range chirps {
+		if chirp.OriginalID.Valid {
+			responses[i].Original = originals[chirp.OriginalID.UUID]
+		}
+	}
+
+	if len(chirps) == 0 {
+		return responses, nil
+	}
+
+	chirpIDs := make([]uuid.UUID, 0, len(responses)+len(originals))
+	for _, response := range responses {
+		chirpIDs = append(chirpIDs, response.ID)
+	}
+	for id := range originals {
+		chirpIDs = append(chirpIDs, id)
+	}
+
+	authorIDs := make([]uuid.UUID, 0, len(responses)+len(originals))
+	for _, response := range responses {
+		authorIDs = append(authorIDs, response.UserID)
+	}
+	for _, original := range originals {
+		authorIDs = append(authorIDs, original.UserID)
+	}
+
+	authors, err := cfg.dbQueries.GetUsersByIDs(ctx, authorIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	authorsByID := make(map[uuid.UUID]*authorResponse, len(authors))
+	for _, author := range authors {
+		authorsByID[author.ID] = &authorResponse{
+			Handle:      author.Handle.String,
+			DisplayName: author.DisplayName,
+		}
+	}
+	for i := range responses {
+		responses[i].Author = authorsByID[responses[i].UserID]
+	}
+	for _, original := range originals {
+		original.Author = authorsByID[original.UserID]
+	}
+
+	mentions, err := cfg.dbQueries.GetChirpsMentions(ctx, chirpIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	mentionsByChirp := make(map[uuid.UUID][]mentionResponse)
+	for _, mention := range mentions {
+		mentionsByChirp[mention.ChirpID] = append(mentionsByChirp[mention.ChirpID], mentionResponse{
+			UserID: mention.UserID,
+			Start:  mention.StartOffset,
+			End:    mention.EndOffset,
+		})
+	}
+	for i := range responses {
+		if m, ok := mentionsByChirp[responses[i].ID]; ok {
+			responses[i].Mentions = m
+		}
+	}
+	for id, original := range originals {
+		if m, ok := mentionsByChirp[id]; ok {
+			original.Mentions = m
+		}
+	}
+
+	if !viewer.Valid {
+		return responses, nil
+	}
+
+	likedIDs, err := cfg.dbQueries.GetLikedChirpIDs(ctx, database.GetLikedChirpIDsParams{
+		UserID:   viewer.UUID,
+		ChirpIds: chirpIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	liked := make(map[uuid.UUID]bool, len(likedIDs))
+	for _, id := range likedIDs {
+		liked[id] = true
+	}
+	for i := range responses {
+		responses[i].LikedByMe = liked[responses[i].ID]
+	}
+	for id, original := range originals {
+		original.LikedByMe = liked[id]
+	}
+
+	return responses, nil
 }
 
 type userRequest struct {
-	Password string `json:"password"`
-	Email    string `json:"email"`
+	Password    string  `json:"password"`
+	Email       string  `json:"email"`
+	Handle      string  `json:"handle"`
+	DisplayName *string `json:"display_name"`
+	Bio         *string `json:"bio"`
 }
 
 func (cfg *apiConfig) handleLogin(w http.ResponseWriter, r *http.Request) {
@@ -44,10 +201,20 @@ func (cfg *apiConfig) handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// throttle check
+	if !cfg.checkLoginThrottle(w, r, body.Email) {
+		return
+	}
+
 	// get user from db
 	user, err := cfg.dbQueries.GetUserByEmail(r.Context(), body.Email)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
+			if err := cfg.recordLoginFailure(r, body.Email); err != nil {
+				utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
 			utils.RespondWithJSON(w, r, err.Error(), http.StatusNotFound)
 			return
 		}
@@ -58,33 +225,70 @@ func (cfg *apiConfig) handleLogin(w http.ResponseWriter, r *http.Request) {
 
 	// password check
 	if err := auth.CheckPasswordHash(body.Password, user.HashedPassword); err != nil {
+		if err := cfg.recordLoginFailure(r, body.Email); err != nil {
+			utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
 		utils.RespondWithError(w, r, "Incorrect email or password", http.StatusUnauthorized)
 		return
 	}
 
+	// second factor, when enabled, is checked by handleLoginTwoFactor
+	twoFactor, err := cfg.twoFactorEnabled(r.Context(), user.ID)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if twoFactor {
+		cfg.respondWithLoginChallenge(w, r, user.ID)
+		return
+	}
+
+	cfg.respondWithLogin(w, r, user)
+}
+
+// respondWithLogin issues the tokens for a user who has fully authenticated.
+func (cfg *apiConfig) respondWithLogin(w http.ResponseWriter, r *http.Request, user database.User) {
+	if err := cfg.clearLoginFailures(r.Context(), user.Email); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	// generate jwt
-	token, err := auth.MakeJWT(user.ID, cfg.jwtSecret, time.Hour)
+	token, err := cfg.jwtKeys.MakeJWT(user.ID, time.Hour)
 	if err != nil {
 		utils.RespondWithJSON(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// generate refresh token
-	refreshToken := auth.MakeRefreshToken()
-	cfg.dbQueries.CreateRfreshToken(r.Context(), database.CreateRfreshTokenParams{
-		Token:  refreshToken,
-		UserID: user.ID,
-	})
+	// generate refresh token, starting a new session
+	refreshToken, err := cfg.startSession(r, user.ID)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	isChirpyRed, err := cfg.isChirpyRed(r.Context(), user.ID)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	// return response
 	response := userResponse{
-		ID:           user.ID,
-		CreatedAt:    user.CreatedAt,
-		UpdatedAt:    user.UpdatedAt,
-		Email:        user.Email,
-		Token:        token,
-		RefreshToken: refreshToken,
-		IsChirpyRed:  user.IsChirpyRed,
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		Token:         token,
+		RefreshToken:  refreshToken,
+		IsChirpyRed:   isChirpyRed,
+		Role:          user.Role,
+		Handle:        user.Handle.String,
+		DisplayName:   user.DisplayName,
+		Bio:           user.Bio,
 	}
 
 	utils.RespondWithJSON(w, r, response, http.StatusOK)
@@ -97,6 +301,16 @@ func (cfg *apiConfig) handleCreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := validateEmail(body.Email); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateProfile(body); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	hash, err := auth.HashPassword(body.Password)
 	if err != nil {
 		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
@@ -106,27 +320,44 @@ func (cfg *apiConfig) handleCreateUser(w http.ResponseWriter, r *http.Request) {
 	user, err := cfg.dbQueries.CreateUser(r.Context(), database.CreateUserParams{
 		Email:          body.Email,
 		HashedPassword: hash,
+		Handle:         sql.NullString{String: body.Handle, Valid: body.Handle != ""},
+		DisplayName:    derefString(body.DisplayName),
+		Bio:            derefString(body.Bio),
 	})
 	if err != nil {
+		if isUniqueViolation(err) {
+			utils.RespondWithError(w, r, "Email or handle already taken", http.StatusConflict)
+			return
+		}
+
 		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// the account exists either way; a lost email can be sent again
+	if err := cfg.sendVerificationEmail(r.Context(), user); err != nil {
+		log.Printf("Failed to send verification email to user %s: %v", user.ID, err)
+	}
+
 	response := userResponse{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed,
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		Role:          user.Role,
+		Handle:        user.Handle.String,
+		DisplayName:   user.DisplayName,
+		Bio:           user.Bio,
 	}
 
 	utils.RespondWithJSON(w, r, response, http.StatusCreated)
 }
 
 func (cfg *apiConfig) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
-	userID, err := cfg.getUserFromToken(r)
+	userID, err := cfg.getUserFromToken(r, "")
 	if err != nil {
-		utils.RespondWithError(w, r, err.Error(), http.StatusUnauthorized)
+		respondWithTokenError(w, r, err)
 		return
 	}
 
@@ -136,42 +367,90 @@ func (cfg *apiConfig) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := validateEmail(body.Email); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateProfile(body); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	hash, err := auth.HashPassword(body.Password)
 	if err != nil {
 		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	previous, err := cfg.dbQueries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	user, err := cfg.dbQueries.UpdateUser(r.Context(), database.UpdateUserParams{
 		ID:             userID,
 		Email:          body.Email,
 		HashedPassword: hash,
+		Handle:         sql.NullString{String: body.Handle, Valid: body.Handle != ""},
+		DisplayName:    toNullString(body.DisplayName),
+		Bio:            toNullString(body.Bio),
 	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			utils.RespondWithError(w, r, "Email or handle already taken", http.StatusConflict)
+			return
+		}
+
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// a new address has to be verified again
+	if user.Email != previous.Email {
+		if err := cfg.sendVerificationEmail(r.Context(), user); err != nil {
+			log.Printf("Failed to send verification email to user %s: %v", user.ID, err)
+		}
+	}
+
+	isChirpyRed, err := cfg.isChirpyRed(r.Context(), user.ID)
 	if err != nil {
 		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	response := userResponse{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed,
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		IsChirpyRed:   isChirpyRed,
+		Role:          user.Role,
+		Handle:        user.Handle.String,
+		DisplayName:   user.DisplayName,
+		Bio:           user.Bio,
 	}
 
 	utils.RespondWithJSON(w, r, response, http.StatusOK)
 }
 
 func (cfg *apiConfig) handleCreateChirp(w http.ResponseWriter, r *http.Request) {
-	userID, err := cfg.getUserFromToken(r)
+	userID, err := cfg.getUserFromToken(r, scopeChirpsWrite)
 	if err != nil {
-		utils.RespondWithError(w, r, err.Error(), http.StatusUnauthorized)
+		respondWithTokenError(w, r, err)
+		return
+	}
+
+	if !cfg.requireVerifiedEmail(w, r, userID) {
 		return
 	}
 
 	type requestBody struct {
-		Body string `json:"body"`
+		Body      string     `json:"body"`
+		InReplyTo *uuid.UUID `json:"in_reply_to"`
+		QuoteOf   *uuid.UUID `json:"quote_of"`
 	}
 
 	var body requestBody
@@ -180,69 +459,179 @@ func (cfg *apiConfig) handleCreateChirp(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if len(body.Body) > 140 {
+	plan, ok := cfg.getEntitlements(w, r, userID)
+	if !ok {
+		return
+	}
+
+	if len(body.Body) > plan.MaxChirpLength {
 		utils.RespondWithError(w, r, "Chirp is too long", http.StatusBadRequest)
 		return
 	}
 
-	chirp, err := cfg.dbQueries.CreateChirp(r.Context(), database.CreateChirpParams{
+	if !cfg.checkChirpRate(w, r, userID, plan) {
+		return
+	}
+
+	params := database.CreateChirpParams{
 		Body:   censorProfane(body.Body),
 		UserID: userID,
-	})
+		Kind:   chirpKindChirp,
+	}
+
+	// replies join the thread of the chirp they answer
+	if body.InReplyTo != nil {
+		parent, err := cfg.getSharedChirp(r.Context(), *body.InReplyTo)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, r, "Parent chirp not found", http.StatusNotFound)
+				return
+			}
+
+			utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		params.ParentID = uuid.NullUUID{UUID: parent.ID, Valid: true}
+		params.RootID = parent.RootID
+		if !parent.RootID.Valid {
+			params.RootID = uuid.NullUUID{UUID: parent.ID, Valid: true}
+		}
+	}
+
+	if body.QuoteOf != nil {
+		original, err := cfg.getSharedChirp(r.Context(), *body.QuoteOf)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				utils.RespondWithError(w, r, "Quoted chirp not found", http.StatusNotFound)
+				return
+			}
+
+			utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		params.Kind = chirpKindQuote
+		params.OriginalID = uuid.NullUUID{UUID: original.ID, Valid: true}
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
 	if err != nil {
 		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	defer tx.Rollback()
 
-	response := chirpResponse{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	chirp, err := qtx.CreateChirp(r.Context(), params)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	utils.RespondWithJSON(w, r, response, http.StatusCreated)
+	if err := tagChirp(r.Context(), qtx, chirp); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := mentionUsers(r.Context(), qtx, chirp); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := enqueueWebhookEvent(r.Context(), qtx, userID, eventChirpCreated, newChirpEventData(chirp)); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	responses, err := cfg.toChirpResponses(r.Context(), uuid.NullUUID{UUID: userID, Valid: true}, []database.Chirp{chirp})
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.RespondWithJSON(w, r, responses[0], http.StatusCreated)
 }
 
 func (cfg *apiConfig) handleGetChirps(w http.ResponseWriter, r *http.Request) {
+	viewer, err := cfg.getViewerFromToken(r)
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
 	queryParams := r.URL.Query()
 
-	params := database.GetChirpsParams{
-		Userid: queryParams.Get("author_id"),
-		Sort:   queryParams.Get("sort"),
+	sort := queryParams.Get("sort")
+	if sort != "desc" && sort != "popular" {
+		sort = "asc"
 	}
 
-	// var authorUUID uuid.UUID
-	// authorID, err := uuid.Parse(queryParams.Get("author_id"))
-	// if err == nil {
-	// 	authorUUID = authorID
-	// }
-	// if authorUUID != uuid.Nil {
-	// 	params.Userid = authorUUID
-	// }
+	page, err := parseSortedPageParams(queryParams, sort)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	chirps, err := cfg.dbQueries.GetChirps(r.Context(), params)
+	var chirps []database.Chirp
+	cursorOf := chirpCursorOf
+	switch sort {
+	case "desc":
+		chirps, err = cfg.dbQueries.GetChirpsPageDesc(r.Context(), database.GetChirpsPageDescParams{
+			AuthorID:        queryParams.Get("author_id"),
+			CursorCreatedAt: page.cursorCreatedAt(),
+			CursorID:        page.cursorID(),
+			PageSize:        page.fetchSize(),
+		})
+	case "popular":
+		chirps, err = cfg.dbQueries.GetChirpsPagePopular(r.Context(), database.GetChirpsPagePopularParams{
+			AuthorID:        queryParams.Get("author_id"),
+			CursorLikeCount: page.cursorLikeCount(),
+			CursorCreatedAt: page.cursorCreatedAt(),
+			CursorID:        page.cursorID(),
+			PageSize:        page.fetchSize(),
+		})
+		cursorOf = popularChirpCursorOf
+	default:
+		chirps, err = cfg.dbQueries.GetChirpsPageAsc(r.Context(), database.GetChirpsPageAscParams{
+			AuthorID:        queryParams.Get("author_id"),
+			CursorCreatedAt: page.cursorCreatedAt(),
+			CursorID:        page.cursorID(),
+			PageSize:        page.fetchSize(),
+		})
+	}
 	if err != nil {
 		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	var response = make([]chirpResponse, 0, len(chirps))
-	for _, chirp := range chirps {
-		response = append(response, chirpResponse{
-			ID:        chirp.ID,
-			CreatedAt: chirp.CreatedAt,
-			UpdatedAt: chirp.UpdatedAt,
-			Body:      chirp.Body,
-			UserID:    chirp.UserID,
-		})
+	chirps, nextCursor := trimPage(page, chirps, cursorOf)
+
+	responses, err := cfg.toChirpResponses(r.Context(), viewer, chirps)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	utils.RespondWithJSON(w, r, response, http.StatusOK)
+	// the next page goes in a header so the body stays the array clients
+	// already expect
+	setNextPageLink(w, r, nextCursor)
+	utils.RespondWithJSON(w, r, responses, http.StatusOK)
 }
 
 func (cfg *apiConfig) handleGetChirpByID(w http.ResponseWriter, r *http.Request) {
+	viewer, err := cfg.getViewerFromToken(r)
+	if err != nil {
+		respondWithTokenError(w, r, err)
+		return
+	}
+
 	v := r.PathValue("chirpID")
 	chirpID, err := uuid.Parse(v)
 	if err != nil {
@@ -261,21 +650,19 @@ func (cfg *apiConfig) handleGetChirpByID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	response := chirpResponse{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
+	responses, err := cfg.toChirpResponses(r.Context(), viewer, []database.Chirp{chirp})
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	utils.RespondWithJSON(w, r, response, http.StatusOK)
+	utils.RespondWithJSON(w, r, responses[0], http.StatusOK)
 }
 
 func (cfg *apiConfig) handleDeleteChirp(w http.ResponseWriter, r *http.Request) {
-	userID, err := cfg.getUserFromToken(r)
+	userID, err := cfg.getUserFromToken(r, scopeChirpsWrite)
 	if err != nil {
-		utils.RespondWithError(w, r, err.Error(), http.StatusUnauthorized)
+		respondWithTokenError(w, r, err)
 		return
 	}
 
@@ -291,19 +678,66 @@ func (cfg *apiConfig) handleDeleteChirp(w http.ResponseWriter, r *http.Request)
 		utils.RespondWithError(w, r, err.Error(), http.StatusNotFound)
 		return
 	}
+	// moderators can take down anyone's chirps
 	if chirp.UserID != userID {
-		utils.RespondWithError(w, r, "Forbidden", http.StatusForbidden)
+		moderator, err := cfg.hasRole(r.Context(), userID, roleModerator)
+		if err != nil {
+			utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !moderator {
+			utils.RespondWithError(w, r, "Forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	// hand the replies over to the deleted chirp's parent so the thread stays connected
+	if err := qtx.ReparentReplies(r.Context(), database.ReparentRepliesParams{
+		NewParentID: chirp.ParentID,
+		OldParentID: chirp.ID,
+	}); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if err := cfg.dbQueries.DeleteChirp(r.Context(), database.DeleteChirpParams{
+	// a deleted root leaves each of its direct replies as the root of its own thread
+	if !chirp.RootID.Valid {
+		if err := qtx.PromoteThreadReplies(r.Context(), chirp.ID); err != nil {
+			utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := qtx.DeleteChirp(r.Context(), database.DeleteChirpParams{
 		ID:     chirpID,
-		UserID: userID,
+		UserID: chirp.UserID,
 	}); err != nil {
 		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	if err := enqueueWebhookEvent(r.Context(), qtx, chirp.UserID, eventChirpDeleted, chirpEventData{
+		ID:     chirp.ID,
+		UserID: chirp.UserID,
+	}); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -314,22 +748,104 @@ func (cfg *apiConfig) handleRefreshToken(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	userID, err := cfg.dbQueries.GetUserFromRefreshToken(r.Context(), refreshToken)
+	stored, err := cfg.dbQueries.GetRefreshToken(r.Context(), auth.HashToken(refreshToken))
 	if err != nil {
-		utils.RespondWithError(w, r, err.Error(), http.StatusUnauthorized)
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, r, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// every refresh revokes the token it used, so a revoked token coming back
+	// means it was copied: end the session for everyone holding it
+	if stored.RevokedAt.Valid {
+		if _, err := cfg.endSession(r.Context(), stored.UserID, stored.SessionID); err != nil {
+			utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		utils.RespondWithError(w, r, "Refresh token reuse detected", http.StatusUnauthorized)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	used, err := qtx.UseRefreshToken(r.Context(), stored.TokenHash)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// either expired, which leaves nothing worth keeping in the session, or
+	// another request just used it, which is reuse like any other
+	if used == 0 {
+		if _, err := qtx.RevokeSession(r.Context(), database.RevokeSessionParams{
+			ID:     stored.SessionID,
+			UserID: stored.UserID,
+		}); err != nil {
+			utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := qtx.RevokeSessionRefreshTokens(r.Context(), stored.SessionID); err != nil {
+			utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		utils.RespondWithError(w, r, "Refresh token expired or already used", http.StatusUnauthorized)
+		return
+	}
+
+	if err := qtx.TouchSession(r.Context(), database.TouchSessionParams{
+		ID:        stored.SessionID,
+		UserAgent: r.UserAgent(),
+		IpAddress: clientIP(r),
+	}); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	newRefreshToken := auth.MakeRefreshToken()
+	if _, err := qtx.CreateRfreshToken(r.Context(), database.CreateRfreshTokenParams{
+		TokenHash: auth.HashToken(newRefreshToken),
+		UserID:    stored.UserID,
+		SessionID: stored.SessionID,
+	}); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	token, err := auth.MakeJWT(userID, cfg.jwtSecret, time.Hour)
+	token, err := cfg.jwtKeys.MakeJWT(stored.UserID, time.Hour)
 	if err != nil {
 		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	response := struct {
-		Token string `json:"token"`
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}{
-		Token: token,
+		Token:        token,
+		RefreshToken: newRefreshToken,
 	}
 
 	utils.RespondWithJSON(w, r, response, http.StatusOK)
@@ -342,8 +858,20 @@ func (cfg *apiConfig) handleRevokeToken(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := cfg.dbQueries.RevokeRefreshToken(r.Context(), token); err != nil {
-		utils.RespondWithError(w, r, err.Error(), http.StatusUnauthorized)
+	stored, err := cfg.dbQueries.GetRefreshToken(r.Context(), auth.HashToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.RespondWithError(w, r, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// revoking a refresh token logs that session out
+	if _, err := cfg.endSession(r.Context(), stored.UserID, stored.SessionID); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
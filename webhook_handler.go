@@ -1,51 +1,246 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/aarondever/chirpy/internal/auth"
+	"github.com/aarondever/chirpy/internal/database"
 	"github.com/aarondever/chirpy/internal/utils"
 	"github.com/google/uuid"
 )
 
+const (
+	polkaTimestampHeader = "X-Polka-Timestamp"
+	polkaSignatureHeader = "X-Polka-Signature"
+	// polkaTolerance bounds how old a delivery can be, which is also how long
+	// a captured one could be replayed for
+	polkaTolerance = 5 * time.Minute
+	maxWebhookBody = 1 << 20
+)
+
+const webhookSourcePolka = "polka"
+
+const (
+	webhookStatusProcessed = "processed"
+	webhookStatusIgnored   = "ignored"
+	webhookStatusFailed    = "failed"
+)
+
+const defaultWebhookEventPageSize = 50
+
+var errWebhookUserNotFound = errors.New("user not found")
+
+type polkaEvent struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
+		UserID uuid.UUID `json:"user_id"`
+	} `json:"data"`
+}
+
+type webhookEventResponse struct {
+	ID          string          `json:"id"`
+	Source      string          `json:"source"`
+	EventType   string          `json:"event_type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int32           `json:"attempts"`
+	LastError   *string         `json:"last_error"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	ProcessedAt *time.Time      `json:"processed_at"`
+}
+
+func toWebhookEventResponse(event database.WebhookEvent) webhookEventResponse {
+	response := webhookEventResponse{
+		ID:         event.ID,
+		Source:     event.Source,
+		EventType:  event.EventType,
+		Payload:    event.Payload,
+		Status:     event.Status,
+		Attempts:   event.Attempts,
+		ReceivedAt: event.ReceivedAt,
+	}
+	if event.LastError.Valid {
+		response.LastError = &event.LastError.String
+	}
+	if event.ProcessedAt.Valid {
+		response.ProcessedAt = &event.ProcessedAt.Time
+	}
+	return response
+}
+
 func (cfg *apiConfig) polkaWebhook(w http.ResponseWriter, r *http.Request) {
-	key, err := auth.GetAPIKey(r.Header)
+	// the signature covers the exact bytes sent, so read them before parsing
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
 	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := auth.VerifyWebhookSignature(
+		cfg.polkaKeys,
+		r.Header.Get(polkaTimestampHeader),
+		r.Header.Get(polkaSignatureHeader),
+		payload,
+		time.Now(),
+		polkaTolerance,
+	); err != nil {
 		utils.RespondWithError(w, r, err.Error(), http.StatusUnauthorized)
 		return
 	}
 
-	if key != cfg.polkaKey {
-		utils.RespondWithError(w, r, "Unauthorized", http.StatusUnauthorized)
+	var body polkaEvent
+	if err := json.Unmarshal(payload, &body); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	type requestBody struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID uuid.UUID `json:"user_id"`
-		} `json:"data"`
+	// events without an id are told apart by content, which still catches
+	// Polka retrying the same delivery
+	eventID := body.ID
+	if eventID == "" {
+		eventID = auth.HashToken(string(payload))
 	}
 
-	var body requestBody
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		utils.RespondWithError(w, r, err.Error(), http.StatusBadRequest)
+	if _, err := cfg.dbQueries.RecordWebhookEvent(r.Context(), database.RecordWebhookEventParams{
+		ID:        eventID,
+		Source:    webhookSourcePolka,
+		EventType: body.Event,
+		Payload:   payload,
+	}); err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if body.Event != "user.upgraded" {
-		w.WriteHeader(http.StatusNoContent)
+	// only one delivery gets to claim the event; a redelivery that arrives
+	// while it is being processed, or after it was, has nothing left to do
+	event, err := cfg.dbQueries.ClaimWebhookEvent(r.Context(), eventID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	_, err = cfg.dbQueries.UpgradeUser(r.Context(), body.Data.UserID)
-	if err != nil && errors.Is(err, sql.ErrNoRows) {
-		w.WriteHeader(http.StatusNotFound)
+	if err := cfg.processWebhookEvent(r.Context(), event); err != nil {
+		if errors.Is(err, errWebhookUserNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func (cfg *apiConfig) handleGetWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	status := sql.NullString{}
+	if v := r.URL.Query().Get("status"); v != "" {
+		status = sql.NullString{String: v, Valid: true}
+	}
+
+	pageSize := int32(defaultWebhookEventPageSize)
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			utils.RespondWithError(w, r, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		pageSize = int32(min(limit, maxPageSize))
+	}
+
+	events, err := cfg.dbQueries.ListWebhookEvents(r.Context(), database.ListWebhookEventsParams{
+		Status:   status,
+		PageSize: pageSize,
+	})
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]webhookEventResponse, 0, len(events))
+	for _, event := range events {
+		response = append(response, toWebhookEventResponse(event))
+	}
+
+	utils.RespondWithJSON(w, r, response, http.StatusOK)
+}
+
+// handleReplayWebhookEvent runs a failed event through processing again, for
+// when whatever made it fail has been fixed.
+func (cfg *apiConfig) handleReplayWebhookEvent(w http.ResponseWriter, r *http.Request) {
+	eventID := r.PathValue("eventID")
+
+	retried, err := cfg.dbQueries.RetryWebhookEvent(r.Context(), eventID)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if retried == 0 {
+		utils.RespondWithError(w, r, "No failed event with that id", http.StatusNotFound)
+		return
+	}
+
+	event, err := cfg.dbQueries.GetWebhookEvent(r.Context(), eventID)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// a failure here is recorded on the event, which is what we return
+	if err := cfg.processWebhookEvent(r.Context(), event); err != nil && !errors.Is(err, errWebhookUserNotFound) {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	event, err = cfg.dbQueries.GetWebhookEvent(r.Context(), eventID)
+	if err != nil {
+		utils.RespondWithError(w, r, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	utils.RespondWithJSON(w, r, toWebhookEventResponse(event), http.StatusOK)
+}
+
+// processWebhookEvent applies a recorded event and stores the outcome on it.
+// The processing error is returned as well so callers can respond to it.
+func (cfg *apiConfig) processWebhookEvent(ctx context.Context, event database.WebhookEvent) error {
+	status, err := cfg.applyPolkaEvent(ctx, event)
+
+	lastError := sql.NullString{}
+	if err != nil {
+		status = webhookStatusFailed
+		lastError = sql.NullString{String: err.Error(), Valid: true}
+	}
+
+	if setErr := cfg.dbQueries.SetWebhookEventStatus(ctx, database.SetWebhookEventStatusParams{
+		Status:    status,
+		LastError: lastError,
+		ID:        event.ID,
+	}); setErr != nil {
+		return setErr
+	}
+
+	return err
+}
+
+func (cfg *apiConfig) applyPolkaEvent(ctx context.Context, event database.WebhookEvent) (string, error) {
+	var body polkaEvent
+	if err := json.Unmarshal(event.Payload, &body); err != nil {
+		return "", err
+	}
+
+	return cfg.applySubscriptionEvent(ctx, body.Event, body.Data.UserID)
+}
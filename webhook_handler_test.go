@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aarondever/chirpy/internal/auth"
+	"github.com/aarondever/chirpy/internal/database"
+	"github.com/go-playground/assert/v2"
+	"github.com/google/uuid"
+)
+
+// TestPolkaWebhookDuplicateDeliveries needs a database migrated to the
+// current schema, e.g. TEST_DB_URL=postgres://localhost:5432/chirpy_test.
+func TestPolkaWebhookDuplicateDeliveries(t *testing.T) {
+	dbURL := os.Getenv("TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DB_URL not set")
+	}
+
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	cfg := &apiConfig{
+		db:        db,
+		dbQueries: database.New(db),
+		polkaKeys: []string{"polka-secret"},
+	}
+
+	user, err := cfg.dbQueries.CreateUser(ctx, database.CreateUserParams{
+		Email:          uuid.NewString() + "@example.com",
+		HashedPassword: "unused",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eventID := uuid.NewString()
+	t.Cleanup(func() {
+		db.ExecContext(ctx, "DELETE FROM webhook_events WHERE id = $1", eventID)
+		db.ExecContext(ctx, "DELETE FROM users WHERE id = $1", user.ID)
+	})
+
+	payload := fmt.Appendf(nil, `{"id":%q,"event":%q,"data":{"user_id":%q}}`, eventID, polkaEventRenewed, user.ID)
+
+	deliver := func() int {
+		ts := time.Now().Unix()
+		req := httptest.NewRequest(http.MethodPost, "/api/polka/webhooks", bytes.NewReader(payload))
+		req.Header.Set(polkaTimestampHeader, strconv.FormatInt(ts, 10))
+		req.Header.Set(polkaSignatureHeader, auth.SignWebhook("polka-secret", ts, payload))
+
+		w := httptest.NewRecorder()
+		cfg.polkaWebhook(w, req)
+		return w.Code
+	}
+
+	// two at once, then one more after both have finished
+	var wg sync.WaitGroup
+	codes := make([]int, 2)
+	for i := range codes {
+		wg.Go(func() {
+			codes[i] = deliver()
+		})
+	}
+	wg.Wait()
+	codes = append(codes, deliver())
+
+	for _, code := range codes {
+		assert.Equal(t, code, http.StatusNoContent)
+	}
+
+	event, err := cfg.dbQueries.GetWebhookEvent(ctx, eventID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, event.Status, webhookStatusProcessed)
+	assert.Equal(t, event.Attempts, int32(3))
+
+	// both timestamps come from the database, so they can be compared: a
+	// renewal applied twice would put the period end two months out
+	subscription, err := cfg.dbQueries.GetSubscription(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, subscription.CurrentPeriodEnd.Before(subscription.CreatedAt.AddDate(0, 1, 1)), true)
+}